@@ -1,12 +1,16 @@
 module my/utils
 
-go 1.17
+go 1.18
 
 require (
-	github.com/hashicorp/go.net v0.0.1
+	golang.org/x/net v0.11.0
 	gorm.io/driver/mysql v1.2.3
 	gorm.io/gorm v1.22.5
+)
+
+require (
 	github.com/go-sql-driver/mysql v1.6.0 // indirect
 	github.com/jinzhu/inflection v1.0.0 // indirect
 	github.com/jinzhu/now v1.1.4 // indirect
+	golang.org/x/text v0.13.0 // indirect
 )
@@ -0,0 +1,63 @@
+package http
+
+import (
+	"net/http"
+)
+
+// AuthProvider 鉴权方式, 包装下一层 RoundTripper, 作用于每一次实际发送的请求(包括重试)
+// 重定向到其他 host 时不再携带鉴权信息
+type AuthProvider interface {
+	Wrap(next http.RoundTripper) http.RoundTripper
+}
+
+// WithAuth 配置 client 的鉴权方式, 可被 WithRequestAuth 覆盖
+// 鉴权位于用户中间件内层, 熔断、限流等内置中间件外层
+func WithAuth(provider AuthProvider) Options {
+	return func(o *options) {
+		o.auth = provider
+	}
+}
+
+// WithRequestAuth 配置单次请求的鉴权方式, 覆盖 client 的鉴权方式
+func WithRequestAuth(provider AuthProvider) DoOptions {
+	return func(o *doOptions) {
+		o.auth = provider
+	}
+}
+
+// authFunc 为请求设置鉴权信息的 AuthProvider
+type authFunc func(req *http.Request)
+
+func (f authFunc) Wrap(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if !authAllowed(req) {
+			return next.RoundTrip(req)
+		}
+		req = req.Clone(req.Context())
+		f(req)
+		return next.RoundTrip(req)
+	})
+}
+
+// BasicAuth HTTP Basic 鉴权
+func BasicAuth(username string, password string) AuthProvider {
+	return authFunc(func(req *http.Request) {
+		req.SetBasicAuth(username, password)
+	})
+}
+
+// BearerToken 使用固定 token 的 Bearer 鉴权
+func BearerToken(token string) AuthProvider {
+	return authFunc(func(req *http.Request) {
+		req.Header.Set("Authorization", "Bearer "+token)
+	})
+}
+
+// authAllowed 请求是否需要鉴权, 重定向到与首次请求不同的 host 时不携带鉴权信息
+func authAllowed(req *http.Request) bool {
+	first := req
+	for first.Response != nil && first.Response.Request != nil {
+		first = first.Response.Request
+	}
+	return first.URL.Host == req.URL.Host
+}
@@ -0,0 +1,219 @@
+package http
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestClientAuth(t *testing.T) {
+	echo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", TextPlain)
+		w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer echo.Close()
+
+	c := NewClient(WithAuth(BasicAuth("user", "pass")))
+	if got := doText(t, c, echo.URL); got != "Basic dXNlcjpwYXNz" {
+		t.Fatalf("basic auth %q", got)
+	}
+	if got := doText(t, c, echo.URL, WithRequestAuth(BearerToken("t1"))); got != "Bearer t1" {
+		t.Fatalf("request bearer auth %q", got)
+	}
+
+	// 重定向到其他 host 时不携带鉴权信息
+	redirect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			t.Errorf("first hop should be authenticated")
+		}
+		http.Redirect(w, r, echo.URL, http.StatusFound)
+	}))
+	defer redirect.Close()
+	if got := doText(t, c, redirect.URL); got != "" {
+		t.Fatalf("auth leaked to redirect target %q", got)
+	}
+}
+
+func TestClientDigestAuth(t *testing.T) {
+	const (
+		realm    = "test"
+		user     = "user"
+		password = "pass"
+	)
+	md5hex := func(s string) string {
+		sum := md5.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+
+	var (
+		lock       sync.Mutex
+		nonce      = "n1"
+		lastNC     int64
+		challenges int32
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		challenge := func(stale bool) {
+			atomic.AddInt32(&challenges, 1)
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Digest realm="%s", nonce="%s", qop="auth,auth-int", opaque="op", algorithm=MD5, stale=%v`, realm, nonce, stale))
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+
+		auth := r.Header.Get("Authorization")
+		if len(auth) < 7 || auth[:7] != "Digest " {
+			challenge(false)
+			return
+		}
+		p := parseAuthParams(auth[7:])
+		if p["nonce"] != nonce {
+			challenge(true)
+			return
+		}
+		nc, _ := strconv.ParseInt(p["nc"], 16, 64)
+		if nc <= lastNC || p["opaque"] != "op" || p["uri"] != r.URL.RequestURI() {
+			t.Errorf("invalid digest params %v last nc %d", p, lastNC)
+		}
+		lastNC = nc
+
+		ha1 := md5hex(p["username"] + ":" + realm + ":" + password)
+		ha2 := md5hex(r.Method + ":" + p["uri"])
+		if p["response"] != md5hex(ha1+":"+nonce+":"+p["nc"]+":"+p["cnonce"]+":auth:"+ha2) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", TextPlain)
+		w.Write([]byte("ok " + p["username"]))
+	}))
+	defer srv.Close()
+
+	c := NewClient(WithAuth(DigestAuth(user, password)))
+	post := func() string {
+		req, err := c.NewRequest(http.MethodPost, WithURL(srv.URL+"/a?b=1"), WithBody(map[string]int{"a": 1}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var text string
+		resp, err := c.Do(req, WithResponseBodyData(&text))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status %d", resp.StatusCode)
+		}
+		return text
+	}
+
+	for i := 0; i < 3; i++ {
+		if got := post(); got != "ok user" {
+			t.Fatalf("digest response %q", got)
+		}
+	}
+	// 首次请求收到质询, 之后复用质询
+	if n := atomic.LoadInt32(&challenges); n != 1 {
+		t.Fatalf("challenges %d", n)
+	}
+
+	// nonce 过期后使用新的 nonce 重新发送
+	lock.Lock()
+	nonce, lastNC = "n2", 0
+	lock.Unlock()
+	if got := post(); got != "ok user" {
+		t.Fatalf("digest response after stale %q", got)
+	}
+	if n := atomic.LoadInt32(&challenges); n != 2 {
+		t.Fatalf("challenges %d", n)
+	}
+
+	// 密码错误时返回 401 响应
+	lock.Lock()
+	lastNC = 0
+	lock.Unlock()
+	c = NewClient(WithAuth(DigestAuth(user, "wrong")))
+	req, _ := c.NewRequest(http.MethodGet, WithURL(srv.URL))
+	resp, err := c.Do(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expect 401, got %v %v", resp, err)
+	}
+}
+
+func TestClientOAuth2ClientCredentials(t *testing.T) {
+	var (
+		issued    int32
+		expiresIn int32 = 3600
+		current   atomic.Value
+	)
+	tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if r.Method != http.MethodPost || r.FormValue("grant_type") != "client_credentials" || id != "id" || secret != "secret" {
+			w.Header().Set("Content-Type", ApplicationJSON)
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_client","error_description":"bad credentials"}`))
+			return
+		}
+		if r.FormValue("scope") != "read write" {
+			t.Errorf("scope %q", r.FormValue("scope"))
+		}
+		token := "token-" + strconv.Itoa(int(atomic.AddInt32(&issued, 1)))
+		current.Store(token)
+		w.Header().Set("Content-Type", ApplicationJSON)
+		fmt.Fprintf(w, `{"access_token":%q,"token_type":"bearer","expires_in":%d}`, token, atomic.LoadInt32(&expiresIn))
+	}))
+	defer tokenSrv.Close()
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+current.Load().(string) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", TextPlain)
+		w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer api.Close()
+
+	source := NewClientCredentials(ClientCredentialsConfig{
+		TokenURL:     tokenSrv.URL,
+		ClientID:     "id",
+		ClientSecret: "secret",
+		Scopes:       []string{"read", "write"},
+	})
+	c := NewClient(WithAuth(source))
+
+	for i := 0; i < 3; i++ {
+		if got := doText(t, c, api.URL); got != "Bearer token-1" {
+			t.Fatalf("response %q", got)
+		}
+	}
+	if n := atomic.LoadInt32(&issued); n != 1 {
+		t.Fatalf("token should be cached, issued %d", n)
+	}
+
+	// 服务端吊销 token 后收到 401, 刷新 token 并重新发送
+	current.Store("revoked")
+	if got := doText(t, c, api.URL); got != "Bearer token-2" {
+		t.Fatalf("response after revoke %q", got)
+	}
+
+	// token 即将过期时提前刷新
+	atomic.StoreInt32(&expiresIn, 5)
+	source.Invalidate()
+	doText(t, c, api.URL)
+	doText(t, c, api.URL)
+	if n := atomic.LoadInt32(&issued); n != 4 {
+		t.Fatalf("token should be refreshed before expiry, issued %d", n)
+	}
+
+	bad := NewClientCredentials(ClientCredentialsConfig{TokenURL: tokenSrv.URL, ClientID: "id", ClientSecret: "wrong"})
+	req, _ := c.NewRequest(http.MethodGet, WithURL(api.URL))
+	if _, err := c.Do(req, WithRequestAuth(bad)); !errors.Is(err, ErrTokenFetch) {
+		t.Fatalf("expect ErrTokenFetch, got %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// rawBody 不经过 MarshalHandler 序列化的请求 body
+type rawBody struct {
+	data   []byte
+	reader io.Reader
+	open   func() (io.ReadCloser, error)
+	size   int64 // 未知时为 -1
+}
+
+// WithBodyBytes 使用已编码的 bytes 作为请求 body, Content-Type 由 WithContentType 指定
+// 与 WithBody、WithBodyString、WithBodyReader、WithBodyFunc 互相覆盖, 以最后配置的为准
+func WithBodyBytes(b []byte) RequestOptions {
+	return withRawBody(&rawBody{data: b, size: int64(len(b))})
+}
+
+// WithBodyString 使用已编码的字符串作为请求 body
+func WithBodyString(s string) RequestOptions {
+	return WithBodyBytes([]byte(s))
+}
+
+// WithBodyReader 使用 r 作为请求 body, size 为 body 长度, 未知时传 -1(使用 chunked 编码发送)
+// r 为 *bytes.Reader、*bytes.Buffer、*strings.Reader 或实现了 io.Seeker 时 body 可重放, 长度未知时自动计算
+// 其他 reader 只能发送一次, 重试与 307/308 重定向时无法重放 body
+// 实现了 io.Seeker 的 reader(如 *os.File)不会被关闭, 由调用方关闭; 其他实现了 io.Closer 的 reader 发送后关闭
+func WithBodyReader(r io.Reader, size int64) RequestOptions {
+	return withRawBody(&rawBody{reader: r, size: size})
+}
+
+// WithBodyFunc 使用 open 打开请求 body, 重试与重定向时再次调用 open 重放 body
+// size 为 body 长度, 未知时传 -1; 为 0 时不调用 open
+func WithBodyFunc(open func() (io.ReadCloser, error), size int64) RequestOptions {
+	return withRawBody(&rawBody{open: open, size: size})
+}
+
+func withRawBody(body *rawBody) RequestOptions {
+	return func(o *requestOptions) {
+		o.body = nil
+		o.rawBody = body
+	}
+}
+
+// newBody 生成 WithBodyReader、WithBodyFunc 的请求 body, 返回 http.NewRequest 使用的 body、重放 body 的 GetBody 与 body 长度
+// 返回的长度为 -1 时由 http.NewRequest 依据 body 类型计算
+func (b *rawBody) newBody() (io.Reader, func() (io.ReadCloser, error), int64, error) {
+	switch {
+	case b.open != nil:
+		if b.size == 0 {
+			// 长度为 0 时 http.Client 不发送 body, 不需要打开
+			return http.NoBody, nil, 0, nil
+		}
+		body, err := b.open()
+		if err != nil {
+			return nil, nil, 0, fmt.Errorf("open body err %w", err)
+		}
+		return body, b.open, b.size, nil
+	case b.reader == nil:
+		return nil, nil, -1, nil
+	}
+
+	switch b.reader.(type) {
+	case *bytes.Reader, *bytes.Buffer, *strings.Reader:
+		// http.NewRequest 会设置 GetBody 与 ContentLength
+		return b.reader, nil, b.size, nil
+	}
+
+	seeker, ok := b.reader.(io.Seeker)
+	if !ok {
+		return b.reader, nil, b.size, nil
+	}
+
+	start, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("seek body err %w", err)
+	}
+	size := b.size
+	if size < 0 {
+		end, err := seeker.Seek(0, io.SeekEnd)
+		if err != nil {
+			return nil, nil, 0, fmt.Errorf("seek body err %w", err)
+		}
+		if _, err = seeker.Seek(start, io.SeekStart); err != nil {
+			return nil, nil, 0, fmt.Errorf("seek body err %w", err)
+		}
+		size = end - start
+	}
+	getBody := func() (io.ReadCloser, error) {
+		if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(b.reader), nil
+	}
+	// 重放时需要再次读取, 不能由 Transport 关闭
+	return ioutil.NopCloser(b.reader), getBody, size, nil
+}
@@ -0,0 +1,115 @@
+package http
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClientRequestBody(t *testing.T) {
+	// 返回 "Content-Type|Content-Length|Transfer-Encoding|body", /redirect 使用 307 重定向到 /
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+			return
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", TextPlain)
+		fmt.Fprintf(w, "%s|%d|%s|%s", r.Header.Get("Content-Type"), r.ContentLength, strings.Join(r.TransferEncoding, ","), b)
+	}))
+	defer srv.Close()
+	c := NewClient()
+
+	if _, got := doRequest(t, c, http.MethodGet, srv.URL, nil); got != "|0||" {
+		t.Fatalf("no body %q", got)
+	}
+	if _, got := doRequest(t, c, http.MethodPost, srv.URL, []RequestOptions{WithBody(nil)}); got != "|0||" {
+		t.Fatalf("nil body %q", got)
+	}
+	if _, got := doRequest(t, c, http.MethodPost, srv.URL+"/redirect", []RequestOptions{WithBody(map[string]int{"a": 1})}); got != `application/json|7||{"a":1}` {
+		t.Fatalf("encoded body %q", got)
+	}
+	if _, got := doRequest(t, c, http.MethodPut, srv.URL+"/redirect", []RequestOptions{WithBodyString("raw"), WithContentType(TextPlain)}); got != "text/plain|3||raw" {
+		t.Fatalf("string body %q", got)
+	}
+	if _, got := doRequest(t, c, http.MethodPut, srv.URL, []RequestOptions{WithBodyBytes([]byte(`{"b":2}`))}); got != `application/json|7||{"b":2}` {
+		t.Fatalf("bytes body %q", got)
+	}
+
+	// 长度未知且不可重放的 reader 使用 chunked 编码
+	r := io.MultiReader(strings.NewReader("chunk"), strings.NewReader("ed"))
+	if _, got := doRequest(t, c, http.MethodPut, srv.URL, []RequestOptions{WithBodyReader(r, -1), WithContentType(ApplicationOctetStream)}); got != "application/octet-stream|-1|chunked|chunked" {
+		t.Fatalf("unknown length reader %q", got)
+	}
+}
+
+func TestClientReplayableBody(t *testing.T) {
+	// 返回 "Content-Type|Content-Length|Transfer-Encoding|body", /redirect 使用 307 重定向到 /
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+			return
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", TextPlain)
+		fmt.Fprintf(w, "%s|%d|%s|%s", r.Header.Get("Content-Type"), r.ContentLength, strings.Join(r.TransferEncoding, ","), b)
+	}))
+	defer srv.Close()
+	c := NewClient()
+
+	// 实现了 io.Seeker 的文件自动计算长度, 307 重定向时重放
+	path := filepath.Join(t.TempDir(), "body")
+	if err := ioutil.WriteFile(path, []byte("0123456789"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	f.Seek(2, io.SeekStart)
+	if _, got := doRequest(t, c, http.MethodPut, srv.URL+"/redirect", []RequestOptions{WithBodyReader(f, -1), WithContentType(TextPlain)}); got != "text/plain|8||23456789" {
+		t.Fatalf("file body %q", got)
+	}
+
+	// WithBodyFunc 在重试时重新打开 body
+	var attempts, opens int32
+	retrySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", TextPlain)
+		fmt.Fprintf(w, "%d|%s", r.ContentLength, b)
+	}))
+	defer retrySrv.Close()
+
+	policy := DefaultRetryPolicy()
+	policy.BaseDelay = time.Millisecond
+	open := func() (io.ReadCloser, error) {
+		atomic.AddInt32(&opens, 1)
+		return ioutil.NopCloser(strings.NewReader("stream")), nil
+	}
+	req, _ := c.NewRequest(http.MethodPut, WithURL(retrySrv.URL), WithBodyFunc(open, 6))
+	var text string
+	if _, err = c.Do(req, WithResponseBodyData(&text), WithRequestRetry(policy)); err != nil {
+		t.Fatal(err)
+	}
+	if text != "6|stream" || atomic.LoadInt32(&opens) != 2 {
+		t.Fatalf("body func %q opens %d", text, opens)
+	}
+
+	// 长度为 0 时不打开 body, 避免打开的 body 未关闭
+	if _, got := doRequest(t, c, http.MethodPut, srv.URL, []RequestOptions{WithBodyFunc(open, 0)}); got != "|0||" || atomic.LoadInt32(&opens) != 2 {
+		t.Fatalf("empty body func %q opens %d", got, opens)
+	}
+}
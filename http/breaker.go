@@ -0,0 +1,279 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen 熔断器处于打开状态, 请求未发送直接失败
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// BreakerState 熔断器状态
+type BreakerState int
+
+const (
+	BreakerClosed   BreakerState = iota // 关闭, 请求正常发送
+	BreakerOpen                         // 打开, 请求直接失败
+	BreakerHalfOpen                     // 半开, 允许少量探测请求
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("BreakerState(%d)", int(s))
+}
+
+// BreakerPolicy 熔断策略, ConsecutiveFailures 与 ErrorRate 至少配置一个
+// 调用方取消的请求(errors.Is(err, context.Canceled))不计入成功或失败, 也不占用半开状态的探测名额, 不会调用 IsFailure
+// 超时(context.DeadlineExceeded, 包括 WithRequestTimeout 与调用方 ctx 的 deadline)无法区分来源, 默认视为失败,
+// 调用方的 deadline 较短时可自定义 IsFailure 排除 errors.Is(err, context.DeadlineExceeded)
+type BreakerPolicy struct {
+	ConsecutiveFailures int           // 连续失败次数达到该值时熔断, 0 表示不启用
+	ErrorRate           float64       // 统计窗口内错误率达到该值时熔断, 取值 (0, 1], 0 表示不启用
+	MinRequests         int           // 统计窗口内请求数达到该值才计算错误率
+	Window              time.Duration // 错误率统计窗口, 默认 10s
+	CoolDown            time.Duration // 熔断后转为半开状态的等待时间, 默认 5s
+	HalfOpenProbes      int           // 半开状态允许的探测请求数, 全部成功后关闭熔断器, 默认 1
+
+	KeyFunc       func(req *http.Request) string                       // 熔断器的 key, 默认为 req.URL.Host
+	IsFailure     func(resp *http.Response, err error) bool            // 判断请求是否失败, 默认网络错误(含超时)与 5xx 响应视为失败, 取消的请求不调用
+	OnStateChange func(key string, from BreakerState, to BreakerState) // 状态变化回调, 在持有锁之外同步调用
+}
+
+// validate 检查熔断策略, 返回的 error 满足 errors.Is(err, ErrInvalidConfig)
+func (p BreakerPolicy) validate() error {
+	if p.ConsecutiveFailures < 0 || p.ErrorRate < 0 || p.ErrorRate > 1 {
+		return fmt.Errorf("%w: invalid circuit breaker policy %+v", ErrInvalidConfig, p)
+	}
+	if p.ConsecutiveFailures == 0 && p.ErrorRate == 0 {
+		return fmt.Errorf("%w: circuit breaker requires ConsecutiveFailures or ErrorRate", ErrInvalidConfig)
+	}
+	return nil
+}
+
+func (p BreakerPolicy) withDefaults() BreakerPolicy {
+	if p.Window <= 0 {
+		p.Window = 10 * time.Second
+	}
+	if p.CoolDown <= 0 {
+		p.CoolDown = 5 * time.Second
+	}
+	if p.HalfOpenProbes <= 0 {
+		p.HalfOpenProbes = 1
+	}
+	if p.KeyFunc == nil {
+		p.KeyFunc = func(req *http.Request) string {
+			return req.URL.Host
+		}
+	}
+	if p.IsFailure == nil {
+		p.IsFailure = func(resp *http.Response, err error) bool {
+			return err != nil || resp.StatusCode >= 500
+		}
+	}
+	return p
+}
+
+// breakerGroup 按 key 管理熔断器
+type breakerGroup struct {
+	policy BreakerPolicy
+
+	lock     sync.Mutex
+	breakers map[string]*breaker
+}
+
+func newBreakerGroup(policy BreakerPolicy) *breakerGroup {
+	return &breakerGroup{
+		policy:   policy.withDefaults(),
+		breakers: make(map[string]*breaker),
+	}
+}
+
+func (g *breakerGroup) get(key string) *breaker {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	b, ok := g.breakers[key]
+	if !ok {
+		b = &breaker{key: key, policy: &g.policy}
+		g.breakers[key] = b
+	}
+	return b
+}
+
+// state 获取 key 对应熔断器的当前状态
+func (g *breakerGroup) state(key string) BreakerState {
+	g.lock.Lock()
+	b, ok := g.breakers[key]
+	g.lock.Unlock()
+	if !ok {
+		return BreakerClosed
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.currentState(time.Now())
+}
+
+func (g *breakerGroup) middleware(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		b := g.get(g.policy.KeyFunc(req))
+		generation, err := b.allow()
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := next.RoundTrip(req)
+		if errors.Is(err, context.Canceled) {
+			b.cancel(generation)
+			return resp, err
+		}
+		b.done(generation, !g.policy.IsFailure(resp, err))
+		return resp, err
+	})
+}
+
+// breaker 单个 key 的熔断器
+type breaker struct {
+	key    string
+	policy *BreakerPolicy
+
+	lock        sync.Mutex
+	state       BreakerState
+	generation  uint64 // 每次切换状态时递增, 状态切换前放行的请求结果不计入新状态
+	openedAt    time.Time
+	consecutive int // 连续失败次数
+
+	windowStart time.Time
+	requests    int
+	failures    int
+
+	probes    int // 半开状态已放行的探测请求数
+	successes int // 半开状态探测成功数
+}
+
+// currentState 打开状态超过 CoolDown 后转为半开, 调用方需持有锁
+func (b *breaker) currentState(now time.Time) BreakerState {
+	if b.state == BreakerOpen && now.Sub(b.openedAt) >= b.policy.CoolDown {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+// allow 判断是否放行请求, 返回放行时的 generation
+func (b *breaker) allow() (uint64, error) {
+	b.lock.Lock()
+	from := b.state
+	state := b.currentState(time.Now())
+	if state != from {
+		b.setState(state)
+	}
+
+	var err error
+	switch state {
+	case BreakerOpen:
+		err = fmt.Errorf("%w: %s", ErrCircuitOpen, b.key)
+	case BreakerHalfOpen:
+		if b.probes >= b.policy.HalfOpenProbes {
+			err = fmt.Errorf("%w: %s is half-open, waiting for probes", ErrCircuitOpen, b.key)
+		} else {
+			b.probes++
+		}
+	}
+	generation := b.generation
+	b.lock.Unlock()
+
+	b.notify(from, state)
+	return generation, err
+}
+
+// done 记录请求结果, generation 与当前不一致时(请求放行后状态已切换)忽略
+func (b *breaker) done(generation uint64, success bool) {
+	b.lock.Lock()
+	if generation != b.generation {
+		b.lock.Unlock()
+		return
+	}
+	from := b.state
+	now := time.Now()
+
+	switch b.state {
+	case BreakerHalfOpen:
+		if !success {
+			b.trip(now)
+		} else if b.successes++; b.successes >= b.policy.HalfOpenProbes {
+			b.setState(BreakerClosed)
+		}
+	case BreakerClosed:
+		if now.Sub(b.windowStart) >= b.policy.Window {
+			b.windowStart, b.requests, b.failures = now, 0, 0
+		}
+		b.requests++
+		if success {
+			b.consecutive = 0
+			break
+		}
+		b.failures++
+		b.consecutive++
+
+		p := b.policy
+		if (p.ConsecutiveFailures > 0 && b.consecutive >= p.ConsecutiveFailures) ||
+			(p.ErrorRate > 0 && b.requests >= p.MinRequests && float64(b.failures)/float64(b.requests) >= p.ErrorRate) {
+			b.trip(now)
+		}
+	}
+	to := b.state
+	b.lock.Unlock()
+
+	b.notify(from, to)
+}
+
+// cancel 请求被取消, 不计入统计, 归还半开状态的探测名额
+func (b *breaker) cancel(generation uint64) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if generation == b.generation && b.state == BreakerHalfOpen {
+		b.probes--
+	}
+}
+
+// trip 打开熔断器, 调用方需持有锁
+func (b *breaker) trip(now time.Time) {
+	b.setState(BreakerOpen)
+	b.openedAt = now
+}
+
+// setState 切换状态并重置统计数据, 调用方需持有锁
+func (b *breaker) setState(state BreakerState) {
+	b.state = state
+	b.generation++
+	b.consecutive, b.requests, b.failures = 0, 0, 0
+	b.windowStart = time.Now()
+	b.probes, b.successes = 0, 0
+}
+
+func (b *breaker) notify(from BreakerState, to BreakerState) {
+	if from != to && b.policy.OnStateChange != nil {
+		b.policy.OnStateChange(b.key, from, to)
+	}
+}
+
+// BreakerState 获取 key 对应熔断器的状态, 未配置 WithCircuitBreaker 时始终为 BreakerClosed
+// key 默认为请求的 host, 见 BreakerPolicy.KeyFunc
+func (c *Client) BreakerState(key string) BreakerState {
+	if c.breakers == nil {
+		return BreakerClosed
+	}
+	return c.breakers.state(key)
+}
@@ -0,0 +1,193 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClientCircuitBreaker(t *testing.T) {
+	var healthy int32
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		if atomic.LoadInt32(&healthy) == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	var changes []string
+	c := NewClient(WithCircuitBreaker(BreakerPolicy{
+		ConsecutiveFailures: 3,
+		CoolDown:            50 * time.Millisecond,
+		OnStateChange: func(key string, from BreakerState, to BreakerState) {
+			changes = append(changes, from.String()+"->"+to.String())
+		},
+	}))
+	key := srv.Listener.Addr().String()
+
+	do := func() error {
+		req, err := c.NewRequest(http.MethodGet, WithURL(srv.URL))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = c.Do(req)
+		return err
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := do(); err != nil {
+			t.Fatalf("request %d err %v", i, err)
+		}
+	}
+	if c.BreakerState(key) != BreakerOpen {
+		t.Fatalf("breaker should be open, got %v", c.BreakerState(key))
+	}
+	if err := do(); !errors.Is(err, ErrCircuitOpen) || atomic.LoadInt32(&hits) != 3 {
+		t.Fatalf("expect fail fast, got %v hits %d", err, hits)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if c.BreakerState(key) != BreakerHalfOpen {
+		t.Fatalf("breaker should be half-open, got %v", c.BreakerState(key))
+	}
+	if err := do(); err != nil {
+		t.Fatal(err)
+	}
+	if c.BreakerState(key) != BreakerOpen {
+		t.Fatalf("failed probe should reopen breaker, got %v", c.BreakerState(key))
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	atomic.StoreInt32(&healthy, 1)
+	if err := do(); err != nil {
+		t.Fatal(err)
+	}
+	if c.BreakerState(key) != BreakerClosed {
+		t.Fatalf("breaker should be closed, got %v", c.BreakerState(key))
+	}
+
+	want := []string{"closed->open", "open->half-open", "half-open->open", "open->half-open", "half-open->closed"}
+	if len(changes) != len(want) {
+		t.Fatalf("state changes %v, want %v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Fatalf("state changes %v, want %v", changes, want)
+		}
+	}
+}
+
+func TestBreakerErrorRate(t *testing.T) {
+	g := newBreakerGroup(BreakerPolicy{ErrorRate: 0.5, MinRequests: 4, Window: time.Minute})
+	b := g.get("k")
+	for i, success := range []bool{true, false, true, false} {
+		if i == 3 && g.state("k") != BreakerClosed {
+			t.Fatalf("breaker should stay closed below MinRequests")
+		}
+		generation, err := b.allow()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.done(generation, success)
+	}
+	if g.state("k") != BreakerOpen {
+		t.Fatalf("breaker should open at 50%% error rate, got %v", g.state("k"))
+	}
+}
+
+func TestBreakerCanceled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			<-r.Context().Done()
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	c := NewClient(WithCircuitBreaker(BreakerPolicy{ConsecutiveFailures: 2, CoolDown: 50 * time.Millisecond}))
+	key := srv.Listener.Addr().String()
+
+	do := func(path string, cancel bool) error {
+		ctx, stop := context.WithCancel(context.Background())
+		defer stop()
+		req, err := c.NewRequest(http.MethodGet, WithURL(srv.URL+path), WithContext(ctx))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cancel {
+			time.AfterFunc(10*time.Millisecond, stop)
+		}
+		_, err = c.Do(req)
+		return err
+	}
+
+	// 取消的请求不计入失败
+	for i := 0; i < 3; i++ {
+		if err := do("/slow", true); !IsCanceled(err) {
+			t.Fatalf("expect canceled, got %v", err)
+		}
+	}
+	if c.BreakerState(key) != BreakerClosed {
+		t.Fatalf("canceled requests should not trip breaker, got %v", c.BreakerState(key))
+	}
+
+	do("/", false)
+	do("/", false)
+	if c.BreakerState(key) != BreakerOpen {
+		t.Fatalf("breaker should be open, got %v", c.BreakerState(key))
+	}
+
+	// 取消的探测请求归还探测名额, 不重新打开熔断器
+	time.Sleep(60 * time.Millisecond)
+	if err := do("/slow", true); !IsCanceled(err) {
+		t.Fatalf("expect canceled probe, got %v", err)
+	}
+	if c.BreakerState(key) != BreakerHalfOpen {
+		t.Fatalf("breaker should stay half-open, got %v", c.BreakerState(key))
+	}
+	if err := do("/", false); errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("probe slot should be released, got %v", err)
+	}
+}
+
+func TestBreakerStaleResult(t *testing.T) {
+	g := newBreakerGroup(BreakerPolicy{ConsecutiveFailures: 1, CoolDown: 20 * time.Millisecond})
+	b := g.get("k")
+
+	// 关闭状态放行的慢请求, 在熔断器转为半开后才返回
+	stale, err := b.allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	generation, _ := b.allow()
+	b.done(generation, false)
+
+	time.Sleep(30 * time.Millisecond)
+	probe, err := b.allow()
+	if err != nil {
+		t.Fatalf("probe should be allowed, got %v", err)
+	}
+	b.done(stale, true)
+	b.cancel(stale)
+	if g.state("k") != BreakerHalfOpen {
+		t.Fatalf("stale result should not close breaker, got %v", g.state("k"))
+	}
+	if _, err = b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("stale cancel should not release probe slot, got %v", err)
+	}
+	b.done(probe, true)
+	if g.state("k") != BreakerClosed {
+		t.Fatalf("probe success should close breaker, got %v", g.state("k"))
+	}
+
+	if err = NewClient(WithCircuitBreaker(BreakerPolicy{CoolDown: time.Second})).Err(); !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("policy without ConsecutiveFailures or ErrorRate: expect ErrInvalidConfig, got %v", err)
+	}
+}
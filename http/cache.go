@@ -0,0 +1,363 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WithCache 开启 GET、HEAD 请求的响应缓存, 按 RFC 9111 处理 Cache-Control、Expires、Vary 与条件请求
+// 缓存过期后使用 ETag(If-None-Match)或 Last-Modified(If-Modified-Since)重新验证, 收到 304 时使用缓存的响应
+// 其他方法的请求成功后删除对应 URL 的缓存; 使用 WithResponseStream、WithResponseWriter 或 WithResumeFile 的请求不使用缓存
+// body 超过 WithCacheMaxEntrySize(默认 10MB)的响应不缓存
+// 带有 Authorization 请求头, 或配置了 WithRequestAuth、WithRequestSigner 的请求不使用缓存; client 级的鉴权与签名视为同一身份, 仍使用缓存
+// 响应是否来自缓存可通过 Response.FromCache 与 Response.Revalidated 获取
+func WithCache(store CacheStore) Options {
+	return func(o *options) {
+		o.cache = store
+	}
+}
+
+// WithCacheMaxEntrySize 配置单个缓存响应 body 的最大字节数, 超过时停止缓冲且不写入缓存, 小于等于 0 时不限制
+func WithCacheMaxEntrySize(size int64) Options {
+	return func(o *options) {
+		o.cacheMaxEntrySize = size
+	}
+}
+
+// WithRequestCache 单次请求是否使用缓存, 为 false 时不读取也不写入缓存
+func WithRequestCache(enable bool) DoOptions {
+	return func(o *doOptions) {
+		o.cache = &enable
+	}
+}
+
+// defaultCacheMaxEntrySize 默认的单个缓存响应 body 最大字节数
+const defaultCacheMaxEntrySize = 10 << 20
+
+// cacheEntry 缓存的响应
+type cacheEntry struct {
+	RequestTime  time.Time           `json:"request_time"`
+	ResponseTime time.Time           `json:"response_time"`
+	Vary         map[string][]string `json:"vary,omitempty"` // Vary 中的请求头在缓存时的值
+	Response     []byte              `json:"response"`       // httputil.DumpResponse 的结果
+}
+
+// heuristicStatusCodes 可缓存的状态码, 见 RFC 9110 15.1
+var heuristicStatusCodes = map[int]bool{
+	200: true, 203: true, 204: true, 300: true, 301: true, 308: true,
+	404: true, 405: true, 410: true, 414: true, 501: true,
+}
+
+// httpCache 响应缓存中间件
+type httpCache struct {
+	store        CacheStore
+	maxEntrySize int64
+}
+
+func (c *httpCache) middleware(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			resp, err := next.RoundTrip(req)
+			if err == nil && resp.StatusCode < 400 {
+				c.store.Delete(cacheKey(http.MethodGet, req))
+				c.store.Delete(cacheKey(http.MethodHead, req))
+			}
+			return resp, err
+		}
+
+		reqCC := parseCacheControl(req.Header)
+		if _, ok := reqCC["no-store"]; ok || !cacheableRequest(req) {
+			return next.RoundTrip(req)
+		}
+
+		key := cacheKey(req.Method, req)
+		entry, cached := c.load(key, req)
+		now := time.Now()
+		if cached != nil && entry.fresh(cached, reqCC, now) {
+			cached.Header.Set("Age", strconv.FormatInt(int64(entry.age(cached, now)/time.Second), 10))
+			setCacheStatus(req, true, false)
+			return cached, nil
+		}
+
+		outgoing := req
+		if cached != nil {
+			etag, lastModified := cached.Header.Get("ETag"), cached.Header.Get("Last-Modified")
+			if etag != "" || lastModified != "" {
+				outgoing = req.Clone(req.Context())
+				if etag != "" {
+					outgoing.Header.Set("If-None-Match", etag)
+				}
+				if lastModified != "" {
+					outgoing.Header.Set("If-Modified-Since", lastModified)
+				}
+			}
+		}
+
+		requestTime := time.Now()
+		resp, err := next.RoundTrip(outgoing)
+		if err != nil {
+			if cached != nil {
+				cached.Body.Close()
+			}
+			return resp, err
+		}
+		responseTime := time.Now()
+
+		if resp.StatusCode == http.StatusNotModified && cached != nil && outgoing != req {
+			drainBody(resp.Body)
+			updateCachedHeader(cached.Header, resp.Header)
+			body, _ := ioutil.ReadAll(cached.Body)
+			cached.Body.Close()
+			c.save(key, req, cached, body, requestTime, responseTime)
+
+			cached.Body = ioutil.NopCloser(bytes.NewReader(body))
+			setCacheStatus(req, true, true)
+			return cached, nil
+		}
+		if cached != nil {
+			cached.Body.Close()
+		}
+
+		tooLarge := c.maxEntrySize > 0 && req.Method != http.MethodHead && resp.ContentLength > c.maxEntrySize
+		if !storeable(resp, responseTime) || tooLarge {
+			c.store.Delete(key)
+			return resp, nil
+		}
+		resp.Body = &cachingBody{
+			ReadCloser: resp.Body,
+			limit:      c.maxEntrySize,
+			done: func(body []byte) {
+				c.save(key, req, resp, body, requestTime, responseTime)
+			},
+		}
+		return resp, nil
+	})
+}
+
+// cacheableRequest 带有 Range 或调用方自行设置的条件请求头时不使用缓存
+// 带有 Authorization 的请求不使用缓存, 避免不同身份的请求之间共享响应, 见 RFC 9111 3.5
+func cacheableRequest(req *http.Request) bool {
+	for _, h := range []string{"Authorization", "Range", "If-None-Match", "If-Modified-Since", "If-Match", "If-Unmodified-Since", "If-Range"} {
+		if req.Header.Get(h) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func cacheKey(method string, req *http.Request) string {
+	return method + " " + req.URL.String()
+}
+
+// load 读取缓存, 缓存不存在、已损坏或 Vary 请求头不匹配时返回 nil
+func (c *httpCache) load(key string, req *http.Request) (*cacheEntry, *http.Response) {
+	b, ok := c.store.Get(key)
+	if !ok {
+		return nil, nil
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(b, &entry); err != nil {
+		c.store.Delete(key)
+		return nil, nil
+	}
+	for name, values := range entry.Vary {
+		if strings.Join(req.Header.Values(name), ",") != strings.Join(values, ",") {
+			return nil, nil
+		}
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(entry.Response)), req)
+	if err != nil {
+		c.store.Delete(key)
+		return nil, nil
+	}
+	return &entry, resp
+}
+
+// save 写入缓存, body 为完整的响应 body
+func (c *httpCache) save(key string, req *http.Request, resp *http.Response, body []byte, requestTime time.Time, responseTime time.Time) {
+	entry := cacheEntry{RequestTime: requestTime, ResponseTime: responseTime}
+	for _, v := range resp.Header.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			if name = http.CanonicalHeaderKey(strings.TrimSpace(name)); name != "" {
+				if entry.Vary == nil {
+					entry.Vary = make(map[string][]string)
+				}
+				entry.Vary[name] = req.Header.Values(name)
+			}
+		}
+	}
+
+	stored := *resp
+	stored.Body = ioutil.NopCloser(bytes.NewReader(body))
+	// HEAD 响应没有 body, 保留源站的 Content-Length
+	if req.Method != http.MethodHead {
+		stored.ContentLength = int64(len(body))
+	}
+	stored.TransferEncoding = nil
+	stored.Header = resp.Header.Clone()
+	stored.Header.Del("Age")
+	dump, err := httputil.DumpResponse(&stored, true)
+	if err != nil {
+		return
+	}
+	entry.Response = dump
+
+	b, err := json.Marshal(&entry)
+	if err != nil {
+		return
+	}
+	c.store.Set(key, b)
+}
+
+// age 缓存响应的当前 age, 见 RFC 9111 4.2.3
+func (e *cacheEntry) age(resp *http.Response, now time.Time) time.Duration {
+	var apparent time.Duration
+	if date, err := http.ParseTime(resp.Header.Get("Date")); err == nil {
+		if d := e.ResponseTime.Sub(date); d > 0 {
+			apparent = d
+		}
+	}
+
+	corrected := e.ResponseTime.Sub(e.RequestTime)
+	if age, err := strconv.ParseInt(resp.Header.Get("Age"), 10, 64); err == nil {
+		corrected += time.Duration(age) * time.Second
+	}
+	if apparent > corrected {
+		corrected = apparent
+	}
+	return corrected + now.Sub(e.ResponseTime)
+}
+
+// fresh 缓存的响应是否可以直接使用, 见 RFC 9111 4.2
+func (e *cacheEntry) fresh(resp *http.Response, reqCC map[string]string, now time.Time) bool {
+	respCC := parseCacheControl(resp.Header)
+	if _, ok := reqCC["no-cache"]; ok {
+		return false
+	}
+	if _, ok := respCC["no-cache"]; ok {
+		return false
+	}
+
+	age := e.age(resp, now)
+	if v, ok := reqCC["max-age"]; ok {
+		if maxAge, err := strconv.ParseInt(v, 10, 64); err == nil && age > time.Duration(maxAge)*time.Second {
+			return false
+		}
+	}
+	return freshnessLifetime(resp, respCC, e.ResponseTime) > age
+}
+
+// freshnessLifetime 响应的有效期: max-age 优先, 其次 Expires, 仅有 Last-Modified 时按其距 Date 的 10% 估算
+// 没有有效的 Date 时以收到响应的时间 responseTime 代替, 见 RFC 9111 4.2.1
+func freshnessLifetime(resp *http.Response, respCC map[string]string, responseTime time.Time) time.Duration {
+	if v, ok := respCC["max-age"]; ok {
+		if maxAge, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return time.Duration(maxAge) * time.Second
+		}
+		return 0
+	}
+
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		date = responseTime
+	}
+	if v := resp.Header.Get("Expires"); v != "" {
+		// 无效的 Expires 视为已过期
+		if expires, err := http.ParseTime(v); err == nil {
+			return expires.Sub(date)
+		}
+		return 0
+	}
+	if lastModified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil && date.After(lastModified) {
+		return date.Sub(lastModified) / 10
+	}
+	return 0
+}
+
+// storeable 响应是否可以缓存, 见 RFC 9111 3
+func storeable(resp *http.Response, responseTime time.Time) bool {
+	if !heuristicStatusCodes[resp.StatusCode] {
+		return false
+	}
+	respCC := parseCacheControl(resp.Header)
+	if _, ok := respCC["no-store"]; ok {
+		return false
+	}
+	for _, v := range resp.Header.Values("Vary") {
+		if strings.TrimSpace(v) == "*" {
+			return false
+		}
+	}
+	if freshnessLifetime(resp, respCC, responseTime) > 0 {
+		return true
+	}
+	// 没有有效期但可以重新验证
+	return resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != ""
+}
+
+// updateCachedHeader 使用 304 响应的请求头更新缓存的响应, 见 RFC 9111 3.2
+func updateCachedHeader(cached http.Header, notModified http.Header) {
+	for k, v := range notModified {
+		switch k {
+		case "Content-Length", "Content-Encoding", "Transfer-Encoding", "Content-Range":
+			continue
+		}
+		cached[k] = v
+	}
+}
+
+// parseCacheControl 解析 Cache-Control, directive 转为小写
+func parseCacheControl(header http.Header) map[string]string {
+	cc := make(map[string]string)
+	for _, line := range header.Values("Cache-Control") {
+		for _, part := range strings.Split(line, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			name, value := part, ""
+			if i := strings.IndexByte(part, '='); i >= 0 {
+				name, value = part[:i], strings.Trim(strings.TrimSpace(part[i+1:]), `"`)
+			}
+			cc[strings.ToLower(strings.TrimSpace(name))] = value
+		}
+	}
+	return cc
+}
+
+// cachingBody 响应 body 读取完成(EOF)时写入缓存, 未读完即关闭或超过 limit 时不写入
+type cachingBody struct {
+	io.ReadCloser
+	buf   bytes.Buffer
+	limit int64 // 小于等于 0 时不限制
+	done  func(body []byte)
+}
+
+func (b *cachingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if b.done == nil {
+		return n, err
+	}
+	if b.limit > 0 && int64(b.buf.Len()+n) > b.limit {
+		// 超过限制后不再缓冲, 释放已缓冲的数据
+		b.done = nil
+		b.buf = bytes.Buffer{}
+		return n, err
+	}
+	b.buf.Write(p[:n])
+	if err == io.EOF {
+		b.done(b.buf.Bytes())
+		b.done = nil
+	}
+	return n, err
+}
@@ -0,0 +1,261 @@
+package http
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClientCacheMaxAge(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		switch r.URL.Path {
+		case "/fresh":
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/no-store":
+			w.Header().Set("Cache-Control", "no-store, max-age=60")
+		case "/vary":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Vary", "Accept-Language")
+		}
+		w.Header().Set("Content-Type", TextPlain)
+		w.Write([]byte(r.URL.Path + " " + r.Header.Get("Accept-Language")))
+	}))
+	defer srv.Close()
+
+	c := NewClient(WithCache(NewMemoryCache(100)))
+	if resp, text := doRequest(t, c, http.MethodGet, srv.URL+"/fresh", nil); resp.FromCache() || text != "/fresh " {
+		t.Fatalf("first request from cache %v body %q", resp.FromCache(), text)
+	}
+	resp, text := doRequest(t, c, http.MethodGet, srv.URL+"/fresh", nil)
+	if !resp.FromCache() || resp.Revalidated() || text != "/fresh " || atomic.LoadInt32(&hits) != 1 {
+		t.Fatalf("second request from cache %v body %q hits %d", resp.FromCache(), text, hits)
+	}
+	if resp.Header.Get("Age") == "" {
+		t.Fatal("cached response should have Age header")
+	}
+
+	if resp, _ := doRequest(t, c, http.MethodGet, srv.URL+"/fresh", nil, WithRequestCache(false)); resp.FromCache() || atomic.LoadInt32(&hits) != 2 {
+		t.Fatalf("WithRequestCache(false) should bypass cache, hits %d", hits)
+	}
+
+	doRequest(t, c, http.MethodGet, srv.URL+"/no-store", nil)
+	if resp, _ := doRequest(t, c, http.MethodGet, srv.URL+"/no-store", nil); resp.FromCache() || atomic.LoadInt32(&hits) != 4 {
+		t.Fatalf("no-store response should not be cached, hits %d", hits)
+	}
+
+	// Vary 的请求头不同时不使用缓存
+	atomic.StoreInt32(&hits, 0)
+	vary := func(lang string) (*Response, string) {
+		req, _ := c.NewRequest(http.MethodGet, WithURL(srv.URL+"/vary"), WithHeader(http.Header{"Accept-Language": {lang}}))
+		var text string
+		resp, err := c.Do(req, WithResponseBodyData(&text))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp, text
+	}
+	vary("en")
+	if resp, text := vary("en"); !resp.FromCache() || text != "/vary en" {
+		t.Fatalf("same Vary header should hit cache: %v %q", resp.FromCache(), text)
+	}
+	if resp, text := vary("zh"); resp.FromCache() || text != "/vary zh" || atomic.LoadInt32(&hits) != 2 {
+		t.Fatalf("different Vary header should miss cache: %v %q hits %d", resp.FromCache(), text, hits)
+	}
+
+	// 其他方法的请求成功后删除缓存
+	req, _ := c.NewRequest(http.MethodPost, WithURL(srv.URL+"/fresh"))
+	if _, err := c.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	if resp, _ := doRequest(t, c, http.MethodGet, srv.URL+"/fresh", nil); resp.FromCache() {
+		t.Fatal("cache should be invalidated by POST")
+	}
+}
+
+func TestClientCacheRevalidate(t *testing.T) {
+	lastModified := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		switch r.URL.Path {
+		case "/etag":
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/last-modified":
+			w.Header().Set("Cache-Control", "max-age=0")
+			w.Header().Set("Last-Modified", lastModified)
+			if r.Header.Get("If-Modified-Since") == lastModified {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", TextPlain)
+		w.Write([]byte(r.URL.Path + " " + r.Header.Get("Accept-Language")))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	store, err := NewDiskCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient(WithCache(store))
+
+	for _, path := range []string{"/etag", "/last-modified"} {
+		atomic.StoreInt32(&hits, 0)
+		doRequest(t, c, http.MethodGet, srv.URL+path, nil)
+		resp, text := doRequest(t, c, http.MethodGet, srv.URL+path, nil)
+		if !resp.FromCache() || !resp.Revalidated() || resp.StatusCode != http.StatusOK || text != path+" " || atomic.LoadInt32(&hits) != 2 {
+			t.Fatalf("%s: from cache %v revalidated %v status %d body %q hits %d",
+				path, resp.FromCache(), resp.Revalidated(), resp.StatusCode, text, hits)
+		}
+	}
+
+	// 磁盘缓存在新的 client 中仍然可用
+	store2, _ := NewDiskCache(dir)
+	c2 := NewClient(WithCache(store2))
+	if resp, text := doRequest(t, c2, http.MethodGet, srv.URL+"/etag", nil); !resp.Revalidated() || text != "/etag " {
+		t.Fatalf("disk cache: revalidated %v body %q", resp.Revalidated(), text)
+	}
+}
+
+func TestMemoryCacheLRU(t *testing.T) {
+	c := NewMemoryCache(2)
+	c.Set("a", []byte("1"))
+	c.Set("b", []byte("2"))
+	c.Get("a")
+	c.Set("c", []byte("3"))
+
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("least recently used entry should be evicted")
+	}
+	if v, ok := c.Get("a"); !ok || string(v) != "1" {
+		t.Fatalf("a = %q %v", v, ok)
+	}
+	c.Delete("a")
+	if c.Len() != 1 {
+		t.Fatalf("len %d", c.Len())
+	}
+}
+
+func TestClientCacheCredentials(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Content-Type", TextPlain)
+		w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer srv.Close()
+
+	c := NewClient(WithCache(NewMemoryCache(100)))
+	do := func(auth AuthProvider, header http.Header) string {
+		req, _ := c.NewRequest(http.MethodGet, WithURL(srv.URL), WithHeader(header))
+		var opts []DoOptions
+		if auth != nil {
+			opts = append(opts, WithRequestAuth(auth))
+		}
+		var text string
+		if _, err := c.Do(req, append(opts, WithResponseBodyData(&text))...); err != nil {
+			t.Fatal(err)
+		}
+		return text
+	}
+
+	// 不同身份的请求不能共享缓存
+	if got := do(BearerToken("alice"), nil); got != "Bearer alice" {
+		t.Fatalf("alice got %q", got)
+	}
+	if got := do(BearerToken("bob"), nil); got != "Bearer bob" {
+		t.Fatalf("bob got %q", got)
+	}
+	if got := do(nil, http.Header{"Authorization": {"carol"}}); got != "carol" {
+		t.Fatalf("carol got %q", got)
+	}
+	if got := do(nil, http.Header{"Authorization": {"dave"}}); got != "dave" {
+		t.Fatalf("dave got %q", got)
+	}
+	if got := do(nil, nil); got != "" {
+		t.Fatalf("anonymous request got %q", got)
+	}
+}
+
+func TestClientCacheLargeAndStreamed(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("ETag", `"v1"`)
+		if r.URL.Path == "/large" {
+			// 分块发送, 没有 Content-Length
+			for i := 0; i < 4; i++ {
+				w.Write(bytes.Repeat([]byte("x"), 512))
+				w.(http.Flusher).Flush()
+			}
+			return
+		}
+		w.Header().Set("Content-Type", TextPlain)
+		w.Write([]byte(r.URL.Path + " " + r.Header.Get("Accept-Language")))
+	}))
+	defer srv.Close()
+
+	c := NewClient(WithCache(NewMemoryCache(100)), WithCacheMaxEntrySize(1024))
+	doRequest(t, c, http.MethodGet, srv.URL+"/large", nil)
+	if resp, text := doRequest(t, c, http.MethodGet, srv.URL+"/large", nil); resp.FromCache() || len(text) != 2048 || atomic.LoadInt32(&hits) != 2 {
+		t.Fatalf("response over max entry size should not be cached: from cache %v len %d hits %d", resp.FromCache(), len(text), hits)
+	}
+
+	// 流式处理的响应不写入缓存, 也不读取缓存
+	atomic.StoreInt32(&hits, 0)
+	stream := func() {
+		req, _ := c.NewRequest(http.MethodGet, WithURL(srv.URL+"/stream"))
+		var buf bytes.Buffer
+		resp, err := c.Do(req, WithResponseWriter(&buf))
+		if err != nil || resp.FromCache() || buf.String() != "/stream " {
+			t.Fatalf("stream: %v from cache %v body %q", err, resp.FromCache(), buf.String())
+		}
+	}
+	stream()
+	if resp, _ := doRequest(t, c, http.MethodGet, srv.URL+"/stream", nil); resp.FromCache() || atomic.LoadInt32(&hits) != 2 {
+		t.Fatalf("streamed response should not be cached, hits %d", hits)
+	}
+	stream()
+	if atomic.LoadInt32(&hits) != 3 {
+		t.Fatalf("streamed request should not read cache, hits %d", hits)
+	}
+}
+
+func TestClientCacheExpiresAndHead(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		// 没有 Date 时以收到响应的时间计算 Expires 的有效期
+		w.Header()["Date"] = nil
+		w.Header().Set("Expires", time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+		w.Header().Set("Content-Type", TextPlain)
+		w.Header().Set("Content-Length", "4096")
+		if r.Method != http.MethodHead {
+			w.Write(bytes.Repeat([]byte("x"), 4096))
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(WithCache(NewMemoryCache(100)))
+	doRequest(t, c, http.MethodGet, srv.URL, nil)
+	if resp, text := doRequest(t, c, http.MethodGet, srv.URL, nil); !resp.FromCache() || len(text) != 4096 || atomic.LoadInt32(&hits) != 1 {
+		t.Fatalf("Expires without Date: from cache %v len %d hits %d", resp.FromCache(), len(text), hits)
+	}
+
+	// HEAD 响应缓存后保留源站的 Content-Length
+	doRequest(t, c, http.MethodHead, srv.URL, nil)
+	if resp, _ := doRequest(t, c, http.MethodHead, srv.URL, nil); !resp.FromCache() || resp.ContentLength != 4096 {
+		t.Fatalf("HEAD from cache %v content length %d", resp.FromCache(), resp.ContentLength)
+	}
+}
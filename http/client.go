@@ -2,101 +2,345 @@ package http
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"reflect"
 )
 
 // Client http 调用通用客户端
 type Client struct {
 	HTTPClient http.Client
+
+	opt options
+	err error // 配置错误, NewRequest 与 Do 将直接返回该错误
+
+	baseURL *url.URL
+
+	breakers *breakerGroup
+
+	middlewares []Middleware // 内置中间件, 位于所有用户中间件内层
 }
 
 // NewClient 创建 client
+// Transport 基于 http.DefaultTransport 的副本创建, 保留其连接池、超时与 HTTP/2 等默认设置
+// 配置错误(如代理配置冲突)可通过 Err 获取, NewRequest 与 Do 也将返回该错误
 func NewClient(opts ...Options) *Client {
 	opt := defaultOptions
 	opt.ExecuteOptions(opts)
 
-	c := &Client{}
+	c := &Client{
+		opt: opt,
+	}
+
+	ts := opt.transport.newTransport()
 
 	// Proxy
-	if opt.socks5 != nil {
-		ts := &http.Transport{
-			Dial: opt.socks5.Dial,
-		}
-		c.HTTPClient.Transport = ts
-	} else if len(opt.proxy) > 0 {
-		proxy := func(_ *http.Request) (*url.URL, error) {
-			return url.Parse(opt.proxy)
-		}
+	if err := opt.proxy.configure(ts, opt.transport.dialer()); err != nil {
+		c.err = err
+	}
+	c.HTTPClient.Transport = ts
 
-		ts := &http.Transport{
-			Proxy: proxy,
+	// base url
+	if opt.baseURL != "" && c.err == nil {
+		var err error
+		if c.baseURL, c.opt.query, err = parseBaseURL(opt.baseURL, opt.query); err != nil {
+			c.err = err
 		}
-		c.HTTPClient.Transport = ts
 	}
 
 	// timeout
 	c.HTTPClient.Timeout = opt.timeout
 
+	// cookie
+	c.HTTPClient.Jar = opt.jar
+
+	if opt.breaker != nil {
+		if err := opt.breaker.validate(); err != nil && c.err == nil {
+			c.err = err
+		}
+		c.breakers = newBreakerGroup(*opt.breaker)
+		c.middlewares = append(c.middlewares, c.breakers.middleware)
+	}
+	if opt.rateLimit != nil || len(opt.hostRateLimits) > 0 {
+		c.middlewares = append(c.middlewares, newRateLimiter(opt.rateLimit, opt.hostRateLimits).middleware)
+	}
+	if opt.proxy.pool != nil {
+		c.middlewares = append(c.middlewares, opt.proxy.pool.middleware)
+	}
+	if opt.decompress {
+		c.middlewares = append(c.middlewares, decompressMiddleware)
+	}
+	// 指标位于最内层, 统计实际发送的请求
+	if opt.metrics != nil {
+		c.middlewares = append(c.middlewares, metricsMiddleware(opt.metrics))
+	}
+
 	return c
 }
 
+// Err 获取 NewClient 时的配置错误, 满足 errors.Is(err, ErrInvalidConfig)
+func (c *Client) Err() error {
+	return c.err
+}
+
 // NewRequest 新建请求
+// 未通过 WithContext 指定 context 时使用 context.Background()
+// 未配置 body(或 WithBody(nil))时请求不带 body, 也不设置 Content-Type; 可重放的 body 会设置 GetBody 与 ContentLength
 func (c *Client) NewRequest(method string, opts ...RequestOptions) (*http.Request, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+
 	opt := defaultRequestOptions
 	opt.ExecuteOptions(opts)
 
-	b, err := json.Marshal(opt.body)
+	var (
+		body            io.Reader
+		getBody         func() (io.ReadCloser, error)
+		contentLength   int64 = -1
+		contentType           = opt.contentType
+		contentEncoding string
+	)
+	switch {
+	case len(opt.multipart) > 0:
+		if opt.body != nil || opt.rawBody != nil {
+			return nil, fmt.Errorf("WithBody can not be used with multipart fields")
+		}
+		body, contentType, getBody = multipartBody(opt.multipart, opt.uploadProgress)
+	case opt.rawBody != nil && opt.rawBody.data == nil:
+		var err error
+		if body, getBody, contentLength, err = opt.rawBody.newBody(); err != nil {
+			return nil, err
+		}
+	case opt.rawBody != nil || opt.body != nil:
+		var b []byte
+		if opt.rawBody != nil {
+			b = opt.rawBody.data
+		} else if handler, ok := c.marshalHandler(opt.contentType); ok {
+			var err error
+			b, err = handler.Marshal(opt.body)
+			if err != nil {
+				return nil, fmt.Errorf("marshal body err %w", err)
+			}
+		} else {
+			return nil, fmt.Errorf("%w %q", ErrNoMarshalHandler, opt.contentType)
+		}
+		// WithBodyBytes、WithBodyString 的 body 可能已由调用方编码, 不压缩
+		if opt.rawBody == nil && c.opt.compressMinSize > 0 && len(b) >= c.opt.compressMinSize {
+			var err error
+			if b, err = gzipBody(b); err != nil {
+				return nil, fmt.Errorf("compress body err %w", err)
+			}
+			contentEncoding = "gzip"
+		}
+		// bytes.Reader 可通过 GetBody 重复读取, 重试与重定向时可重放 body
+		body = bytes.NewReader(b)
+	}
+
+	rawURL, err := c.requestURL(&opt)
 	if err != nil {
-		return nil, fmt.Errorf("marshal body err %v", err)
+		return nil, err
 	}
-	body := bytes.NewBuffer(b)
 
-	req, err := http.NewRequest(method, opt.url, body)
+	req, err := http.NewRequestWithContext(withRoute(opt.ctx, &opt), method, rawURL, body)
 	if err != nil {
-		return nil, fmt.Errorf("http.NewRequest err %v", err)
+		return nil, fmt.Errorf("http.NewRequest err %w", err)
+	}
+	if getBody != nil {
+		req.GetBody = getBody
+	}
+	if contentLength >= 0 {
+		req.ContentLength = contentLength
+		if contentLength == 0 && getBody != nil {
+			// 长度为 0 时 http.Client 不发送 body
+			req.Body, req.GetBody = http.NoBody, nil
+		}
 	}
 
-	req.Header = opt.header
+	// 每个请求使用新的 header, 避免请求之间共享 header(如 cookie jar 写入的 Cookie)
+	req.Header = mergeHeader(c.opt.header, opt.header)
 
-	// http Content-Type
-	req.Header.Set("Content-Type", opt.contentType)
+	// http Content-Type, 没有 body(包括长度为 0 的 body)时不设置
+	if req.Body != nil && req.Body != http.NoBody {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if contentEncoding != "" {
+		req.Header.Set("Content-Encoding", contentEncoding)
+	}
 
 	return req, nil
 }
 
+// NewRequestWithContext 使用 ctx 新建请求, ctx 的取消与超时会作用于请求的整个执行过程
+func (c *Client) NewRequestWithContext(ctx context.Context, method string, opts ...RequestOptions) (*http.Request, error) {
+	return c.NewRequest(method, append([]RequestOptions{WithContext(ctx)}, opts...)...)
+}
+
 // Do 执行请求
 // resp.Body 已统一关闭, 调用者不需要再关闭
-// 解析 body 失败将会返回 errorutils.SyntaxError
+// 响应 body 依据响应的 Content-Type 选择 MarshalHandler 解析, 未返回 Content-Type 时按 JSON 解析
+// 发送失败返回 *TransportError, 可通过 IsCanceled、IsTimeout 区分调用方取消、超时与网络错误
+// 读取 body 失败返回 *ReadBodyError, 解析 body 失败返回 *DecodeError, 解析目标不是非 nil 指针时返回 ErrInvalidTarget
+// 配置了 WithMaxResponseSize 或 WithRequestMaxResponseSize 时响应 body 超过限制返回 *ReadBodyError, Err 为 *ResponseTooLargeError
+// 配置了 WithStatusCheck 或 WithResponseStatusCheck 时非 2xx 响应返回 *StatusError, 同时返回 Response
+// 配置了 WithResponseErrorData 时 4xx/5xx 响应解析到错误数据并返回 *StatusError, StatusError.Payload 为解析后的错误数据
+// body 为空时不解析, 解析失败时 Payload 为 nil, 可通过 errors.As 从 StatusError 中获取 *DecodeError
+// 配置了 WithRetry 或 WithRequestRetry 时按重试策略重试
+// 配置了 WithResponseStream、WithResponseWriter 或 WithResumeFile 时 2xx 响应 body 以流的方式处理, 不会读入内存
 func (c *Client) Do(req *http.Request, opts ...DoOptions) (*Response, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+
 	opt := defaultDoOptions
 	opt.ExecuteOptions(opts)
 
-	resp, err := c.HTTPClient.Do(req)
+	// 解析目标不是指针时无法写回数据, 在发送请求前返回错误
+	if err := checkTarget("WithResponseBodyData", opt.responseData); err != nil {
+		return nil, err
+	}
+	if err := checkTarget("WithResponseErrorData", opt.responseErrorData); err != nil {
+		return nil, err
+	}
+
+	req = withResponseMeta(req)
+	if opt.proxy != nil {
+		var err error
+		if req, err = c.opt.proxy.withRequestProxy(req, *opt.proxy); err != nil {
+			return nil, err
+		}
+	}
+
+	hc := c.HTTPClient
+	hc.Transport = c.transport(&opt)
+	if opt.timeout > 0 {
+		// 单次请求的超时时间覆盖 client 的超时时间
+		hc.Timeout = 0
+
+		ctx, cancel := context.WithTimeout(req.Context(), opt.timeout)
+		defer cancel()
+		req = req.WithContext(ctx)
+	}
+
+	var offset int64
+	if opt.resumeFile != "" {
+		req, offset = prepareResume(req, opt.resumeFile)
+	}
+
+	resp, err := c.send(&hc, req, &opt)
 	if err != nil {
-		return nil, fmt.Errorf("do request err %v", err)
+		return nil, &TransportError{Method: req.Method, URL: req.URL.String(), Err: err}
 	}
 	defer resp.Body.Close()
 
+	maxResponseSize := c.opt.maxResponseSize
+	if opt.maxResponseSize != nil {
+		maxResponseSize = *opt.maxResponseSize
+	}
+	if maxResponseSize > 0 {
+		if err = limitBody(resp, maxResponseSize); err != nil {
+			return nil, &ReadBodyError{Method: req.Method, URL: req.URL.String(), StatusCode: resp.StatusCode, Err: err}
+		}
+	}
+
+	if opt.stream != nil || opt.resumeFile != "" {
+		streamed, err := streamResponse(resp, &opt, offset)
+		if err != nil {
+			return nil, &ReadBodyError{Method: req.Method, URL: req.URL.String(), StatusCode: resp.StatusCode, Err: err}
+		}
+		if streamed {
+			return (*Response)(resp), nil
+		}
+	}
+
 	// 必须全部读完, 否则会关闭连接无法使用长连接方式
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("read body err %v", err)
+		return nil, &ReadBodyError{Method: req.Method, URL: req.URL.String(), StatusCode: resp.StatusCode, Err: err}
 	}
 
 	if opt.responseReader != nil {
 		*opt.responseReader = bytes.NewBuffer(body)
 	} else if opt.response != nil {
 		*opt.response = body
-	} else if opt.responseData != nil {
-		err = json.Unmarshal(body, opt.responseData)
-		if err != nil {
-			return nil, fmt.Errorf("json.Unmarshal err %v body %s opt.responseData %v url %v", err, body, opt.responseData, req.URL)
+	}
+
+	if opt.responseErrorData != nil && resp.StatusCode >= 400 {
+		// 错误响应可能是空 body、纯文本或 HTML, 解析失败时仍返回 StatusError
+		statusErr := newStatusError(req, resp, body, nil)
+		if len(body) > 0 {
+			if err = c.decodeBody(req, resp, body, opt.responseErrorData); err != nil {
+				statusErr.Err = err
+			} else {
+				statusErr.Payload = opt.responseErrorData
+			}
+		}
+		return (*Response)(resp), statusErr
+	}
+
+	if c.checkStatus(&opt) && (resp.StatusCode < 200 || resp.StatusCode >= 300) {
+		return (*Response)(resp), newStatusError(req, resp, body, nil)
+	}
+
+	// 204 与 HEAD 响应没有 body, 不解析
+	noBody := len(body) == 0 && (opt.emptyBody || resp.StatusCode == http.StatusNoContent || req.Method == http.MethodHead)
+	if opt.responseReader == nil && opt.response == nil && opt.responseData != nil && !noBody {
+		if err = c.decodeBody(req, resp, body, opt.responseData); err != nil {
+			return nil, err
 		}
 	}
 
 	return (*Response)(resp), nil
 }
+
+// checkTarget 检查解析目标是否为非 nil 指针, data 为 nil 表示未配置
+func checkTarget(option string, data interface{}) error {
+	if data == nil {
+		return nil
+	}
+	if v := reflect.ValueOf(data); v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("%w: %s got %T", ErrInvalidTarget, option, data)
+	}
+	return nil
+}
+
+// checkStatus 是否将非 2xx 响应转换为 StatusError, 请求级配置优先
+func (c *Client) checkStatus(opt *doOptions) bool {
+	if opt.checkStatus != nil {
+		return *opt.checkStatus
+	}
+	return c.opt.checkStatus
+}
+
+// decodeBody 依据响应的 Content-Type 解析 body 到 data, 未返回 Content-Type 时按 JSON 解析
+func (c *Client) decodeBody(req *http.Request, resp *http.Response, body []byte, data interface{}) error {
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = ApplicationJSON
+	}
+
+	err := ErrNoMarshalHandler
+	if handler, ok := c.marshalHandler(contentType); ok {
+		err = handler.Unmarshal(body, data)
+	}
+	if err != nil {
+		return &DecodeError{
+			Method:      req.Method,
+			URL:         req.URL.String(),
+			StatusCode:  resp.StatusCode,
+			ContentType: contentType,
+			Body:        truncateBody(body),
+			Err:         err,
+		}
+	}
+	return nil
+}
+
+// marshalHandler 获取 contentType 对应的 MarshalHandler, 优先使用 WithMarshalHandler 配置的
+func (c *Client) marshalHandler(contentType string) (MarshalHandler, bool) {
+	return lookupMarshalHandler(c.opt.marshalers, contentType)
+}
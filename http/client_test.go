@@ -1,70 +1,717 @@
 package http
 
 import (
-	"fmt"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"my/utils/http/httpmock"
 )
 
 func TestClient(t *testing.T) {
+	srv := httpmock.NewServer(t)
+	srv.Expect(http.MethodGet, "/").RespondJSON(http.StatusOK, map[string]string{"hello": "world"})
+
 	c := NewClient(WithTimeout(time.Second * 20))
-	url := fmt.Sprintf("http://www.baidu.com")
-	req, err := c.NewRequest(http.MethodGet, WithURL(url))
+	req, err := c.NewRequest(http.MethodGet, WithURL(srv.URL))
 	if err != nil {
-		t.Error(err)
-		return
+		t.Fatal(err)
 	}
 
-	var data interface{}
-	resp, err := c.Do(req, WithResponseBodyData(data))
+	var data map[string]interface{}
+	resp, err := c.Do(req, WithResponseBodyData(&data))
 	if err != nil || !resp.IsOK() {
-		t.Log(fmt.Errorf("http do fail or response status err %v, url %v, status %v", err, req, resp))
+		t.Fatalf("http do fail or response status err %v, url %v, status %v", err, req.URL, resp)
+	}
+	if data["hello"] != "world" {
+		t.Fatalf("data %v", data)
 	}
-
-	t.Log(data)
-	t.Log(resp)
 }
 
 func TestClient2(t *testing.T) {
+	srv := httpmock.NewServer(t)
+	srv.Expect(http.MethodGet, "/").WithHeader("token", "sssss").Respond(http.StatusOK, "ok")
+
 	c := NewClient(WithTimeout(time.Second * 20))
 
 	header := http.Header{}
 	header.Add("token", "sssss")
 
+	u, _ := url.Parse(srv.URL)
 	req, err := c.NewRequest(http.MethodGet,
-		WithUrlBuild("http", "www.baidu.com", ""),
+		WithUrlBuild("http", u.Host, ""),
 		WithHeader(header))
 	if err != nil {
-		t.Error(err)
-		return
+		t.Fatal(err)
 	}
 
-	var data interface{}
-	resp, err := c.Do(req, WithResponseBodyData(data))
+	var data string
+	resp, err := c.Do(req, WithResponseBodyData(&data))
 	if err != nil || !resp.IsOK() {
-		t.Log(fmt.Errorf("http do fail or response status err %v, url %v, status %v", err, req.URL, resp.Status))
+		t.Fatalf("http do fail or response status err %v, url %v, status %v", err, req.URL, resp)
+	}
+	if data != "ok" {
+		t.Fatalf("data %q", data)
 	}
-
-	t.Log(data)
-	t.Log(resp)
 }
 
 func TestClient3(t *testing.T) {
+	tr := httpmock.NewTransport(t)
+	tr.Expect(http.MethodPost, "/").RespondJSON(http.StatusOK, []int{1, 2})
+
 	c := NewClient(WithTimeout(time.Second * 20))
-	url := fmt.Sprintf("http://www.baidu.com")
-	req, err := c.NewRequest(http.MethodPost, WithURL(url))
+	c.HTTPClient.Transport = tr
+	req, err := c.NewRequest(http.MethodPost, WithURL("http://www.baidu.com"))
 	if err != nil {
-		t.Error(err)
-		return
+		t.Fatal(err)
 	}
 
-	var data interface{}
-	resp, err := c.Do(req, WithResponseBodyData(data))
+	var data []int
+	resp, err := c.Do(req, WithResponseBodyData(&data))
 	if err != nil || !resp.IsOK() {
-		t.Log(fmt.Errorf("http do fail or response status err %v, url %v, status %v", err, req, resp))
+		t.Fatalf("http do fail or response status err %v, url %v, status %v", err, req.URL, resp)
+	}
+	if len(data) != 2 || len(tr.Requests()) != 1 || tr.Requests()[0].URL.Host != "www.baidu.com" {
+		t.Fatalf("data %v requests %v", data, tr.Requests())
+	}
+}
+
+func TestClientMarshalHandler(t *testing.T) {
+	type payload struct {
+		Name string `json:"name" xml:"name"`
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		switch r.Header.Get("Content-Type") {
+		case ApplicationXML:
+			w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		case ApplicationUrlencoded:
+			w.Header().Set("Content-Type", ApplicationUrlencoded)
+		case "application/x-custom":
+			w.Header().Set("Content-Type", TextPlain)
+		}
+		w.Write(b)
+	}))
+	defer srv.Close()
+
+	c := NewClient()
+
+	req, err := c.NewRequest(http.MethodPost, WithURL(srv.URL), WithContentType(ApplicationXML), WithBody(payload{Name: "xml"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var xmlData payload
+	if _, err = c.Do(req, WithResponseBodyData(&xmlData)); err != nil || xmlData.Name != "xml" {
+		t.Fatalf("xml decode err %v data %+v", err, xmlData)
+	}
+
+	req, err = c.NewRequest(http.MethodPost, WithURL(srv.URL), WithContentType(ApplicationUrlencoded), WithBody(map[string]string{"name": "form"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var formData map[string]string
+	if _, err = c.Do(req, WithResponseBodyData(&formData)); err != nil || formData["name"] != "form" {
+		t.Fatalf("form decode err %v data %+v", err, formData)
+	}
+
+	req, err = c.NewRequest(http.MethodPost, WithURL(srv.URL), WithContentType("application/x-custom"), WithBody("custom"))
+	if err == nil {
+		t.Fatalf("expect error for unregistered content type")
+	}
+
+	c = NewClient(WithMarshalHandler("application/x-custom", TextMarshaler{}))
+	req, err = c.NewRequest(http.MethodPost, WithURL(srv.URL), WithContentType("application/x-custom"), WithBody("custom"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var text string
+	if _, err = c.Do(req, WithResponseBodyData(&text)); err != nil || text != "custom" {
+		t.Fatalf("text decode err %v data %q", err, text)
+	}
+}
+
+func TestClientContext(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(200 * time.Millisecond):
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(WithTimeout(50 * time.Millisecond))
+
+	req, err := c.NewRequest(http.MethodGet, WithURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.Do(req); !IsTimeout(err) || IsCanceled(err) {
+		t.Fatalf("expect client timeout, got %v", err)
+	}
+
+	req, err = c.NewRequest(http.MethodGet, WithURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.Do(req, WithRequestTimeout(time.Second)); err != nil {
+		t.Fatalf("request timeout should override client timeout, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	req, err = c.NewRequestWithContext(ctx, http.MethodGet, WithURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.Do(req, WithRequestTimeout(time.Second)); !IsCanceled(err) || IsTimeout(err) {
+		t.Fatalf("expect canceled, got %v", err)
+	}
+}
+
+func TestClientRetry(t *testing.T) {
+	var attempts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		if atomic.AddInt32(&attempts, 1)%3 != 0 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(b)
+	}))
+	defer srv.Close()
+
+	policy := DefaultRetryPolicy()
+	policy.BaseDelay = time.Millisecond
+	c := NewClient(WithRetry(policy))
+
+	req, err := c.NewRequest(http.MethodPut, WithURL(srv.URL), WithContentType(TextPlain), WithBody("replay"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var text string
+	resp, err := c.Do(req, WithResponseBodyData(&text))
+	if err != nil || !resp.IsOK() || text != "replay" || atomic.LoadInt32(&attempts) != 3 {
+		t.Fatalf("retry err %v attempts %d body %q", err, attempts, text)
+	}
+
+	atomic.StoreInt32(&attempts, 0)
+	req, err = c.NewRequest(http.MethodPost, WithURL(srv.URL), WithContentType(TextPlain), WithBody("replay"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = c.Do(req)
+	if err != nil || resp.StatusCode != http.StatusServiceUnavailable || atomic.LoadInt32(&attempts) != 1 {
+		t.Fatalf("post should not retry, err %v attempts %d", err, attempts)
+	}
+
+	atomic.StoreInt32(&attempts, 0)
+	policy.RetryNonIdempotent = true
+	req, err = c.NewRequest(http.MethodPost, WithURL(srv.URL), WithContentType(TextPlain), WithBody("replay"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = c.Do(req, WithRequestRetry(policy), WithResponseBodyData(&text))
+	if err != nil || !resp.IsOK() || text != "replay" || atomic.LoadInt32(&attempts) != 3 {
+		t.Fatalf("post retry err %v attempts %d body %q", err, attempts, text)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"3", 3 * time.Second, true},
+		{"-1", 0, false},
+		{now.Add(time.Minute).Format(http.TimeFormat), time.Minute, true},
+		{"soon", 0, false},
+		{"86400", maxRetryAfter, true},
+		{now.AddDate(1, 0, 0).Format(http.TimeFormat), maxRetryAfter, true},
+		{"9223372036854775807", 0, false},
+		{"99999999999999999999", 0, false},
+	}
+	for _, cs := range cases {
+		got, ok := parseRetryAfter(cs.value, now)
+		if got != cs.want || ok != cs.ok {
+			t.Errorf("parseRetryAfter(%q) = %v %v, want %v %v", cs.value, got, ok, cs.want, cs.ok)
+		}
+	}
+}
+
+func TestClientMiddleware(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Trace")))
+	}))
+	defer srv.Close()
+
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				order = append(order, name)
+				req = req.Clone(req.Context())
+				req.Header.Add("X-Trace", name)
+				return next.RoundTrip(req)
+			})
+		}
+	}
+
+	c := NewClient(WithMiddleware(trace("a"), trace("b")), WithMiddleware(trace("c")))
+	req, err := c.NewRequest(http.MethodGet, WithURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var body []byte
+	if _, err = c.Do(req, WithRequestMiddleware(trace("d")), WithResponseBody(&body)); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(order, ","); got != "a,b,c,d" || string(body) != "a" {
+		t.Fatalf("middleware order %s body %s", got, body)
+	}
+}
+
+func TestClientMultipart(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		result := map[string]string{"name": r.FormValue("name")}
+		for field, files := range r.MultipartForm.File {
+			f, _ := files[0].Open()
+			b, _ := ioutil.ReadAll(f)
+			f.Close()
+			result[field] = files[0].Filename + ":" + files[0].Header.Get("Content-Type") + ":" + string(b)
+		}
+		w.Header().Set("Content-Type", ApplicationJSON)
+		json.NewEncoder(w).Encode(result)
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "a.txt")
+	if err := ioutil.WriteFile(path, []byte("from file"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var written int64
+	c := NewClient()
+	req, err := c.NewRequest(http.MethodPost, WithURL(srv.URL),
+		WithMultipartField("name", "upload"),
+		WithMultipartFile("file", path),
+		WithMultipartReader("reader", "r.bin", ApplicationOctetStream, strings.NewReader("from reader")),
+		WithMultipartBytes("bytes", "b.json", ApplicationJSON, []byte(`{}`)),
+		WithUploadProgress(func(n int64) { written = n }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.GetBody != nil {
+		t.Fatalf("body with reader part should not be replayable")
+	}
+
+	var result map[string]string
+	resp, err := c.Do(req, WithResponseBodyData(&result))
+	if err != nil || !resp.IsOK() {
+		t.Fatalf("multipart err %v resp %v", err, resp)
+	}
+	want := map[string]string{
+		"name":   "upload",
+		"file":   "a.txt:text/plain; charset=utf-8:from file",
+		"reader": "r.bin:application/octet-stream:from reader",
+		"bytes":  "b.json:application/json:{}",
+	}
+	for k, v := range want {
+		if result[k] != v {
+			t.Errorf("field %s = %q, want %q", k, result[k], v)
+		}
+	}
+	if written == 0 {
+		t.Errorf("upload progress not reported")
+	}
+}
+
+type querySort string
+
+func (s querySort) EncodeQuery(key string, values url.Values) error {
+	values.Set(key, "-"+string(s))
+	return nil
+}
+
+func TestEncodeValues(t *testing.T) {
+	type page struct {
+		Num  int `url:"page_num"`
+		Size int `url:"page_size,omitempty"`
+	}
+	type filter struct {
+		Status []string `url:"status,comma"`
+	}
+	name := "n"
+	v := struct {
+		page
+		Name    *string   `url:"name"`
+		Empty   *string   `url:"empty"`
+		IDs     []int64   `url:"id"`
+		Since   time.Time `url:"since" layout:"2006-01-02"`
+		Until   time.Time `url:"until,unix"`
+		Zero    time.Time `url:"zero,omitempty"`
+		Sort    querySort `url:"sort"`
+		Filter  filter    `url:"filter"`
+		Ignored string    `url:"-"`
+		Raw     bool
+	}{
+		page:    page{Num: 1},
+		Name:    &name,
+		IDs:     []int64{1, 2},
+		Since:   time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC),
+		Until:   time.Unix(1641092645, 0),
+		Sort:    "created",
+		Filter:  filter{Status: []string{"a", "b"}},
+		Ignored: "x",
+		Raw:     true,
+	}
+
+	values, err := EncodeValues(&v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Raw=true&filter%5Bstatus%5D=a%2Cb&id=1&id=2&name=n&page_num=1&since=2022-01-02&sort=-created&until=1641092645"
+	if got := values.Encode(); got != want {
+		t.Fatalf("EncodeValues = %s, want %s", got, want)
+	}
+}
+
+func TestClientQuery(t *testing.T) {
+	c := NewClient()
+	req, err := c.NewRequest(http.MethodGet,
+		WithURL("http://example.com/path?q=x%2By&a=1"),
+		WithQuery(url.Values{"a": {"2"}}),
+		WithQueryMap(map[string]interface{}{"ids": []int{3, 4}}),
+		WithQueryStruct(struct {
+			Q string `url:"q,omitempty"`
+		}{Q: "&="}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// url 中已有的 query 保持原样, 新的参数追加在之后
+	if got, want := req.URL.RawQuery, "q=x%2By&a=1&a=2&ids=3&ids=4&q=%26%3D"; got != want {
+		t.Fatalf("query = %s, want %s", got, want)
+	}
+
+	req, err = c.NewRequest(http.MethodPost, WithURL("http://example.com"), WithContentType(ApplicationUrlencoded), WithBody(struct {
+		Name string `url:"name"`
+	}{Name: "a b"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadAll(req.Body); string(b) != "name=a+b" {
+		t.Fatalf("form body = %s", b)
+	}
+}
+
+func TestClientStream(t *testing.T) {
+	content := strings.Repeat("0123456789", 1000)
+	modTime := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "data.txt", modTime, strings.NewReader(content))
+	}))
+	defer srv.Close()
+
+	c := NewClient()
+
+	var buf strings.Builder
+	var read, total int64
+	req, err := c.NewRequest(http.MethodGet, WithURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.Do(req, WithResponseWriter(&buf), WithDownloadProgress(func(r, t int64) { read, total = r, t }))
+	if err != nil || buf.String() != content || read != int64(len(content)) || total != int64(len(content)) {
+		t.Fatalf("stream err %v len %d progress %d/%d", err, buf.Len(), read, total)
+	}
+
+	// 读取部分内容后关闭 body 中止下载
+	req, _ = c.NewRequest(http.MethodGet, WithURL(srv.URL))
+	head := make([]byte, 10)
+	_, err = c.Do(req, WithResponseStream(func(body io.ReadCloser) error {
+		if _, err := io.ReadFull(body, head); err != nil {
+			return err
+		}
+		return body.Close()
+	}))
+	if err != nil || string(head) != content[:10] {
+		t.Fatalf("stream close err %v head %q", err, head)
+	}
+
+	path := filepath.Join(t.TempDir(), "data.txt")
+	if err = ioutil.WriteFile(path, []byte(content[:3000]), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(path+resumeSuffix, []byte(`"v1"`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	req, err = c.NewRequest(http.MethodGet, WithURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := c.Do(req, WithResumeFile(path), WithDownloadProgress(func(r, t int64) { read, total = r, t }))
+	if err != nil || resp.StatusCode != http.StatusPartialContent {
+		t.Fatalf("resume err %v resp %v", err, resp)
+	}
+	if b, _ := ioutil.ReadFile(path); string(b) != content || read != int64(len(content)) {
+		t.Fatalf("resume content len %d progress %d", len(b), read)
+	}
+	if _, err = os.Stat(path + resumeSuffix); !os.IsNotExist(err) {
+		t.Fatalf("resume file should be removed, err %v", err)
 	}
 
-	t.Log(data)
-	t.Log(resp)
+	// 校验值不一致时重新下载完整内容
+	if err = ioutil.WriteFile(path, []byte("stale"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(path+resumeSuffix, []byte(`"v0"`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	req, err = c.NewRequest(http.MethodGet, WithURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = c.Do(req, WithResumeFile(path))
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("restart err %v resp %v", err, resp)
+	}
+	if b, _ := ioutil.ReadFile(path); string(b) != content {
+		t.Fatalf("restart content len %d", len(b))
+	}
+
+	// 本地文件比服务端资源大时返回错误并删除本地文件, 再次请求时重新下载
+	if err = ioutil.WriteFile(path, []byte(content+"extra"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(path+resumeSuffix, []byte(`"v1"`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	req, _ = c.NewRequest(http.MethodGet, WithURL(srv.URL))
+	var readErr *ReadBodyError
+	if _, err = c.Do(req, WithResumeFile(path)); !errors.As(err, &readErr) || readErr.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+		t.Fatalf("expect ReadBodyError for stale file, got %v", err)
+	}
+	if _, err = os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("stale file should be removed, err %v", err)
+	}
+	req, _ = c.NewRequest(http.MethodGet, WithURL(srv.URL))
+	if resp, err = c.Do(req, WithResumeFile(path)); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("download after stale file err %v resp %v", err, resp)
+	}
+	if b, _ := ioutil.ReadFile(path); string(b) != content {
+		t.Fatalf("download after stale file content len %d", len(b))
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/bad":
+			w.Header().Set("Content-Type", ApplicationJSON)
+			w.Write([]byte("{bad json"))
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(strings.Repeat("x", 2*maxErrorBodySize)))
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient()
+	var data map[string]interface{}
+
+	req, _ := c.NewRequest(http.MethodGet, WithURL(srv.URL+"/bad"))
+	_, err := c.Do(req, WithResponseBodyData(&data))
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) || decodeErr.ContentType != ApplicationJSON || string(decodeErr.Body) != "{bad json" {
+		t.Fatalf("expect DecodeError, got %v", err)
+	}
+
+	req, _ = c.NewRequest(http.MethodGet, WithURL(srv.URL+"/missing"))
+	resp, err := c.Do(req, WithResponseStatusCheck(true))
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound || len(statusErr.Body) != maxErrorBodySize ||
+		statusErr.Method != http.MethodGet || statusErr.URL != srv.URL+"/missing" || resp == nil {
+		t.Fatalf("expect StatusError, got %v", err)
+	}
+
+	c = NewClient(WithStatusCheck())
+	req, _ = c.NewRequest(http.MethodGet, WithURL(srv.URL+"/missing"))
+	if _, err = c.Do(req); !errors.As(err, &statusErr) {
+		t.Fatalf("expect StatusError, got %v", err)
+	}
+	req, _ = c.NewRequest(http.MethodGet, WithURL(srv.URL+"/missing"))
+	if _, err = c.Do(req, WithResponseStatusCheck(false)); err != nil {
+		t.Fatalf("status check disabled, got %v", err)
+	}
+
+	req, _ = c.NewRequest(http.MethodGet, WithURL("http://127.0.0.1:1"))
+	_, err = c.Do(req)
+	var transportErr *TransportError
+	if !errors.As(err, &transportErr) || transportErr.Method != http.MethodGet {
+		t.Fatalf("expect TransportError, got %v", err)
+	}
+
+	_, err = c.NewRequest(http.MethodPost, WithContentType("application/x-unknown"), WithBody("x"))
+	if !errors.Is(err, ErrNoMarshalHandler) {
+		t.Fatalf("expect ErrNoMarshalHandler, got %v", err)
+	}
+}
+
+func TestClientResponseErrorData(t *testing.T) {
+	type apiError struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ApplicationJSON)
+		switch r.URL.Query().Get("fail") {
+		case "json":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code":1001,"msg":"invalid"}`))
+			return
+		case "text":
+			w.Header().Set("Content-Type", TextPlain)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("Service Unavailable"))
+			return
+		case "html":
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte("<html>Bad Gateway</html>"))
+			return
+		case "empty":
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"name":"ok"}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient()
+
+	var data struct {
+		Name string `json:"name"`
+	}
+	var apiErr apiError
+	req, _ := c.NewRequest(http.MethodGet, WithURL(srv.URL), WithQuery(url.Values{"fail": {"json"}}))
+	resp, err := c.Do(req, WithResponseBodyData(&data), WithResponseErrorData(&apiErr))
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expect StatusError, got %v", err)
+	}
+	if payload, ok := statusErr.Payload.(*apiError); !ok || payload.Code != 1001 || payload.Msg != "invalid" || data.Name != "" {
+		t.Fatalf("payload %+v data %+v", statusErr.Payload, data)
+	}
+
+	// 无法解析或为空的错误响应仍返回 StatusError, Payload 为 nil
+	for fail, status := range map[string]int{"text": http.StatusServiceUnavailable, "html": http.StatusBadGateway, "empty": http.StatusNotFound} {
+		req, _ = c.NewRequest(http.MethodGet, WithURL(srv.URL), WithQuery(url.Values{"fail": {fail}}))
+		_, err = c.Do(req, WithResponseErrorData(&apiError{}))
+		if !errors.As(err, &statusErr) || statusErr.StatusCode != status || statusErr.Payload != nil {
+			t.Fatalf("%s: expect StatusError, got %v", fail, err)
+		}
+		var decodeErr *DecodeError
+		if errors.As(err, &decodeErr) != (fail != "empty") {
+			t.Fatalf("%s: decode error %v", fail, err)
+		}
+	}
+
+	apiErr = apiError{}
+	req, _ = c.NewRequest(http.MethodGet, WithURL(srv.URL))
+	if _, err = c.Do(req, WithResponseBodyData(&data), WithResponseErrorData(&apiErr)); err != nil || data.Name != "ok" || apiErr.Code != 0 {
+		t.Fatalf("success err %v data %+v error data %+v", err, data, apiErr)
+	}
+}
+
+func TestClientRecorderCompression(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "gzip" {
+			t.Errorf("request body should be compressed")
+		}
+		w.Header().Set("Content-Type", ApplicationJSON)
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		zw.Write([]byte(`{"access_token":"secret-token"}`))
+		zw.Close()
+	}))
+	defer srv.Close()
+
+	cassette := filepath.Join(t.TempDir(), "compressed.json")
+	do := func(mode httpmock.RecorderMode) string {
+		rec, err := httpmock.NewRecorder(httpmock.RecorderConfig{Cassette: cassette, Mode: mode})
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := NewClient(WithRequestCompression(1), WithResponseDecompression())
+		c.HTTPClient.Transport = rec
+		req, _ := c.NewRequest(http.MethodPost, WithURL(srv.URL), WithBody(map[string]string{"client_secret": "secret-client"}))
+		var data map[string]string
+		if _, err = c.Do(req, WithResponseBodyData(&data)); err != nil {
+			t.Fatal(err)
+		}
+		return data["access_token"]
+	}
+
+	if got := do(httpmock.ModeRecord); got != "secret-token" {
+		t.Fatalf("record token %q", got)
+	}
+	b, err := ioutil.ReadFile(cassette)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "secret-") || strings.Contains(string(b), "body_base64") {
+		t.Fatalf("compressed bodies should be decoded and redacted:\n%s", b)
+	}
+	if got := do(httpmock.ModeReplay); got != httpmock.Redacted {
+		t.Fatalf("replay token %q", got)
+	}
+}
+
+func TestClientRecorderTransport(t *testing.T) {
+	// 代理替身不转发请求, 请求只能经由 client 的 Transport 到达
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", TextPlain)
+		w.Write([]byte("via proxy " + r.Host))
+	}))
+	defer proxy.Close()
+
+	c := NewClient(WithProxy(proxy.URL))
+	rec, err := httpmock.NewRecorder(httpmock.RecorderConfig{
+		Cassette:  filepath.Join(t.TempDir(), "proxy.json"),
+		Mode:      httpmock.ModeRecord,
+		Transport: c.HTTPClient.Transport,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.HTTPClient.Transport = rec
+	if got := doText(t, c, "http://example.test/a"); got != "via proxy example.test" {
+		t.Fatalf("record through client transport %q", got)
+	}
+	if len(rec.Interactions()) != 1 {
+		t.Fatalf("interactions %d", len(rec.Interactions()))
+	}
 }
@@ -0,0 +1,183 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// WithRequestCompression 请求 body 不小于 minSize 字节时使用 gzip 压缩, 并设置 Content-Encoding: gzip
+// 仅对 WithBody 序列化的 body 生效, WithBodyBytes 等原始 body 与 multipart body 不压缩; minSize 小于等于 0 时不压缩
+func WithRequestCompression(minSize int) Options {
+	return func(o *options) {
+		o.compressMinSize = minSize
+	}
+}
+
+// WithResponseDecompression 由 client 解压 gzip、deflate 编码的响应
+// 未设置 Accept-Encoding 的请求发送 "Accept-Encoding: gzip, deflate", 解压后删除 Content-Encoding 与 Content-Length
+// 用于自定义 Transport 关闭了 Go 内置的 gzip 解压, 或需要支持 deflate 的场景
+func WithResponseDecompression() Options {
+	return func(o *options) {
+		o.decompress = true
+	}
+}
+
+// WithMaxResponseSize 限制响应 body(解压后)的最大字节数, 超过时读取 body 返回 *ResponseTooLargeError
+// 可防止超大响应或压缩炸弹耗尽内存, maxSize 小于等于 0 时不限制
+func WithMaxResponseSize(maxSize int64) Options {
+	return func(o *options) {
+		o.maxResponseSize = maxSize
+	}
+}
+
+// WithRequestMaxResponseSize 限制单次请求响应 body 的最大字节数, 覆盖 client 的 WithMaxResponseSize, 小于等于 0 时不限制
+func WithRequestMaxResponseSize(maxSize int64) DoOptions {
+	return func(o *doOptions) {
+		o.maxResponseSize = &maxSize
+	}
+}
+
+// ResponseTooLargeError 响应 body 超过 WithMaxResponseSize 配置的大小
+type ResponseTooLargeError struct {
+	Limit         int64
+	ContentLength int64 // 响应声明的长度, 未声明时为 -1
+}
+
+func (e *ResponseTooLargeError) Error() string {
+	if e.ContentLength >= 0 {
+		return fmt.Sprintf("response body too large: content length %d exceeds limit %d", e.ContentLength, e.Limit)
+	}
+	return fmt.Sprintf("response body too large: exceeds limit %d", e.Limit)
+}
+
+// gzipBody gzip 压缩 body
+func gzipBody(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decompressMiddleware 解压 gzip、deflate 响应
+func decompressMiddleware(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if req.Header.Get("Accept-Encoding") == "" {
+			req = req.Clone(req.Context())
+			req.Header.Set("Accept-Encoding", "gzip, deflate")
+		}
+
+		resp, err := next.RoundTrip(req)
+		if err != nil || req.Method == http.MethodHead || resp.Body == nil || resp.Body == http.NoBody {
+			return resp, err
+		}
+
+		var body io.ReadCloser
+		switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+		case "gzip", "x-gzip":
+			body = &decodedBody{body: resp.Body, newReader: func(r io.Reader) (io.Reader, error) {
+				return gzip.NewReader(r)
+			}}
+		case "deflate":
+			body = &decodedBody{body: resp.Body, newReader: newDeflateReader}
+		default:
+			return resp, nil
+		}
+
+		resp.Body = body
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = -1
+		resp.Uncompressed = true
+		return resp, nil
+	})
+}
+
+// newDeflateReader HTTP 的 deflate 编码应为 zlib 格式, 部分服务端返回不带 zlib 头的原始 deflate 数据, 两者都支持
+func newDeflateReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if len(header) == 2 && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+// decodedBody 首次读取时创建解压 reader, 避免 RoundTrip 阶段读取 body
+type decodedBody struct {
+	body      io.ReadCloser
+	newReader func(r io.Reader) (io.Reader, error)
+
+	r   io.Reader
+	err error
+}
+
+func (b *decodedBody) Read(p []byte) (int, error) {
+	if b.r == nil && b.err == nil {
+		b.r, b.err = b.newReader(b.body)
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	return b.r.Read(p)
+}
+
+func (b *decodedBody) Close() error {
+	if c, ok := b.r.(io.Closer); ok {
+		c.Close()
+	}
+	return b.body.Close()
+}
+
+// limitBody 限制响应 body 的大小, 声明的长度超过限制时立即返回错误
+// HEAD 请求与 204、304 响应没有 body, Content-Length 只是资源的长度, 只限制实际读取的字节
+func limitBody(resp *http.Response, limit int64) error {
+	noBody := (resp.Request != nil && resp.Request.Method == http.MethodHead) ||
+		resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified
+	if !noBody && resp.ContentLength > limit {
+		return &ResponseTooLargeError{Limit: limit, ContentLength: resp.ContentLength}
+	}
+	resp.Body = &limitedBody{body: resp.Body, limit: limit, remaining: limit}
+	return nil
+}
+
+// limitedBody 读取超过 limit 字节时返回 *ResponseTooLargeError
+type limitedBody struct {
+	body      io.ReadCloser
+	limit     int64
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, &ResponseTooLargeError{Limit: b.limit, ContentLength: -1}
+	}
+	// 多读 1 字节以判断是否超过限制
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.body.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		return n + int(b.remaining), &ResponseTooLargeError{Limit: b.limit, ContentLength: -1}
+	}
+	return n, err
+}
+
+func (b *limitedBody) Close() error {
+	return b.body.Close()
+}
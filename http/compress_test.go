@@ -0,0 +1,155 @@
+package http
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClientRequestCompression(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			body = zr
+		}
+		b, _ := ioutil.ReadAll(body)
+		w.Header().Set("Content-Type", TextPlain)
+		w.Write([]byte(r.Header.Get("Content-Encoding") + " " + string(b)))
+	}))
+	defer srv.Close()
+
+	c := NewClient(WithRequestCompression(32))
+	post := func(body interface{}) string {
+		req, err := c.NewRequest(http.MethodPost, WithURL(srv.URL), WithBody(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var text string
+		if _, err = c.Do(req, WithResponseBodyData(&text)); err != nil {
+			t.Fatal(err)
+		}
+		return text
+	}
+
+	if got := post(map[string]string{"a": "1"}); got != ` {"a":"1"}` {
+		t.Fatalf("small body %q", got)
+	}
+	large := map[string]string{"a": strings.Repeat("x", 64)}
+	if got := post(large); got != `gzip {"a":"`+strings.Repeat("x", 64)+`"}` {
+		t.Fatalf("large body %q", got)
+	}
+
+	// 原始 body 可能已编码, 不压缩
+	raw := strings.Repeat("y", 64)
+	if _, got := doRequest(t, c, http.MethodPost, srv.URL, []RequestOptions{WithBodyString(raw)}); got != " "+raw {
+		t.Fatalf("raw body %q", got)
+	}
+}
+
+func TestClientResponseDecompression(t *testing.T) {
+	const text = "hello compressed world"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		var zw io.WriteCloser
+		switch r.URL.Path {
+		case "/gzip":
+			zw = gzip.NewWriter(&buf)
+		case "/deflate":
+			zw = zlib.NewWriter(&buf)
+		case "/raw-deflate":
+			zw, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+		}
+		zw.Write([]byte(text))
+		zw.Close()
+
+		encoding := strings.TrimPrefix(r.URL.Path[1:], "raw-")
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), encoding) {
+			t.Errorf("Accept-Encoding %q", r.Header.Get("Accept-Encoding"))
+		}
+		w.Header().Set("Content-Type", TextPlain)
+		w.Header().Set("Content-Encoding", encoding)
+		w.Write(buf.Bytes())
+	}))
+	defer srv.Close()
+
+	c := NewClient(WithResponseDecompression())
+	c.HTTPClient.Transport.(*http.Transport).DisableCompression = true
+
+	for _, path := range []string{"/gzip", "/deflate", "/raw-deflate"} {
+		req, _ := c.NewRequest(http.MethodGet, WithURL(srv.URL+path))
+		var got string
+		resp, err := c.Do(req, WithResponseBodyData(&got))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != text || resp.Header.Get("Content-Encoding") != "" || !resp.Uncompressed {
+			t.Fatalf("%s: body %q Content-Encoding %q", path, got, resp.Header.Get("Content-Encoding"))
+		}
+	}
+}
+
+func TestClientMaxResponseSize(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", TextPlain)
+		switch r.URL.Path {
+		case "/large":
+			w.Header().Set("Content-Length", "4096")
+			w.Write(bytes.Repeat([]byte("x"), 4096))
+		case "/chunked":
+			for i := 0; i < 4; i++ {
+				w.Write(bytes.Repeat([]byte("x"), 1024))
+				w.(http.Flusher).Flush()
+			}
+		case "/bomb":
+			// 压缩后约 16KB, 解压后 16MB
+			w.Header().Set("Content-Encoding", "gzip")
+			zw := gzip.NewWriter(w)
+			zw.Write(make([]byte, 16<<20))
+			zw.Close()
+		default:
+			w.Write([]byte("ok"))
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(WithMaxResponseSize(1024))
+	for _, path := range []string{"/large", "/chunked", "/bomb"} {
+		req, _ := c.NewRequest(http.MethodGet, WithURL(srv.URL+path))
+		var body []byte
+		_, err := c.Do(req, WithResponseBody(&body))
+		var tooLarge *ResponseTooLargeError
+		if !errors.As(err, &tooLarge) || tooLarge.Limit != 1024 {
+			t.Fatalf("%s: expect ResponseTooLargeError, got %v", path, err)
+		}
+		var readErr *ReadBodyError
+		if !errors.As(err, &readErr) {
+			t.Fatalf("%s: expect ReadBodyError, got %T", path, err)
+		}
+	}
+
+	if got := doText(t, c, srv.URL+"/ok"); got != "ok" {
+		t.Fatalf("small response %q", got)
+	}
+	// HEAD 响应没有 body, 不按 Content-Length 限制
+	if resp, _ := doRequest(t, c, http.MethodHead, srv.URL+"/large", nil); resp.ContentLength != 4096 {
+		t.Fatalf("HEAD content length %d", resp.ContentLength)
+	}
+	req, _ := c.NewRequest(http.MethodGet, WithURL(srv.URL+"/large"))
+	var body []byte
+	if _, err := c.Do(req, WithResponseBody(&body), WithRequestMaxResponseSize(0)); err != nil || len(body) != 4096 {
+		t.Fatalf("WithRequestMaxResponseSize(0) should disable limit: %d %v", len(body), err)
+	}
+}
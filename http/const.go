@@ -5,6 +5,10 @@ const (
 	ApplicationUrlencoded  = "application/x-www-form-urlencoded"
 	ApplicationOctetStream = "application/octet-stream"
 	ApplicationZIP         = "application/zip"
+	ApplicationXML         = "application/xml"
+
+	TextPlain = "text/plain"
+	TextXML   = "text/xml"
 
 	MultipartFormdata = "multipart/form-data"
 )
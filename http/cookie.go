@@ -0,0 +1,303 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// WithSession 开启会话, 使用 NewCookieJar 创建的 CookieJar 保存响应的 cookie 并在之后的请求中发送
+// 可通过 Client.CookieJar 导出、导入或清除 cookie
+func WithSession() Options {
+	return func(o *options) {
+		o.jar = NewCookieJar()
+	}
+}
+
+// WithCookieJar 配置 cookie 存储, jar 为 *CookieJar 时可通过 Client.CookieJar 获取
+func WithCookieJar(jar http.CookieJar) Options {
+	return func(o *options) {
+		o.jar = jar
+	}
+}
+
+// CookieJar 获取 WithSession 或 WithCookieJar 配置的 *CookieJar, 未配置或 jar 为其他类型时返回 nil
+func (c *Client) CookieJar() *CookieJar {
+	jar, _ := c.HTTPClient.Jar.(*CookieJar)
+	return jar
+}
+
+// CookieJar 基于 net/http/cookiejar 的 cookie 存储, 使用 publicsuffix 列表校验 cookie 的 domain
+// 额外记录已保存的 cookie, 支持导出到文件、从文件导入以及按域名查看与清除
+type CookieJar struct {
+	lock    sync.Mutex
+	jar     *cookiejar.Jar
+	entries map[string]*SavedCookie // key 为 domain;path;name
+}
+
+// SavedCookie 导出的 cookie
+type SavedCookie struct {
+	Name     string        `json:"name"`
+	Value    string        `json:"value"`
+	Domain   string        `json:"domain"`              // 不带前导 "." 的域名
+	HostOnly bool          `json:"host_only,omitempty"` // 为 true 时仅发送给 Domain 本身, 不包含子域名
+	Path     string        `json:"path"`
+	Expires  time.Time     `json:"expires,omitempty"` // 零值表示会话 cookie
+	Secure   bool          `json:"secure,omitempty"`
+	HttpOnly bool          `json:"http_only,omitempty"`
+	SameSite http.SameSite `json:"same_site,omitempty"`
+}
+
+// Cookie 转换为 http.Cookie
+func (s *SavedCookie) Cookie() *http.Cookie {
+	c := &http.Cookie{
+		Name:     s.Name,
+		Value:    s.Value,
+		Path:     s.Path,
+		Expires:  s.Expires,
+		Secure:   s.Secure,
+		HttpOnly: s.HttpOnly,
+		SameSite: s.SameSite,
+	}
+	if !s.HostOnly {
+		c.Domain = s.Domain
+	}
+	return c
+}
+
+func (s *SavedCookie) key() string {
+	return s.Domain + ";" + s.Path + ";" + s.Name
+}
+
+func (s *SavedCookie) expired(now time.Time) bool {
+	return !s.Expires.IsZero() && !s.Expires.After(now)
+}
+
+// matchDomain cookie 是否属于 domain, domain 的子域名的 cookie 也视为属于 domain
+func (s *SavedCookie) matchDomain(domain string) bool {
+	domain = strings.TrimPrefix(strings.ToLower(domain), ".")
+	return s.Domain == domain || strings.HasSuffix(s.Domain, "."+domain)
+}
+
+// NewCookieJar 创建 CookieJar
+func NewCookieJar() *CookieJar {
+	return &CookieJar{
+		jar:     newCookieJar(),
+		entries: make(map[string]*SavedCookie),
+	}
+}
+
+func newCookieJar() *cookiejar.Jar {
+	// cookiejar.New 仅在 Options 无效时返回错误
+	jar, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	return jar
+}
+
+// SetCookies 实现 http.CookieJar
+func (j *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	j.jar.SetCookies(u, cookies)
+
+	now := time.Now()
+	for _, c := range cookies {
+		s, ok := newSavedCookie(u, c, now)
+		if !ok {
+			continue
+		}
+		if s.expired(now) {
+			delete(j.entries, s.key())
+		} else {
+			j.entries[s.key()] = s
+		}
+	}
+}
+
+// Cookies 实现 http.CookieJar
+func (j *CookieJar) Cookies(u *url.URL) []*http.Cookie {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	return j.jar.Cookies(u)
+}
+
+// DomainCookies 获取 domain 及其子域名下未过期的 cookie, 按 Domain、Path、Name 排序
+func (j *CookieJar) DomainCookies(domain string) []*SavedCookie {
+	return j.saved(func(s *SavedCookie) bool {
+		return s.matchDomain(domain)
+	})
+}
+
+// ClearDomain 清除 domain 及其子域名下的 cookie
+func (j *CookieJar) ClearDomain(domain string) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	for k, s := range j.entries {
+		if s.matchDomain(domain) {
+			delete(j.entries, k)
+		}
+	}
+	j.rebuild()
+}
+
+// Clear 清除所有 cookie
+func (j *CookieJar) Clear() {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	j.entries = make(map[string]*SavedCookie)
+	j.jar = newCookieJar()
+}
+
+// Export 导出所有未过期的 cookie(包括会话 cookie)
+func (j *CookieJar) Export() []*SavedCookie {
+	return j.saved(func(*SavedCookie) bool {
+		return true
+	})
+}
+
+// Import 导入 cookie, 已过期的 cookie 将被忽略, 与已有 cookie 的 Domain、Path、Name 相同时覆盖已有 cookie
+func (j *CookieJar) Import(cookies []*SavedCookie) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	now := time.Now()
+	for _, s := range cookies {
+		if s == nil || s.expired(now) {
+			continue
+		}
+		s := *s
+		s.Domain = strings.TrimPrefix(strings.ToLower(s.Domain), ".")
+		j.entries[s.key()] = &s
+		j.jar.SetCookies(s.url(), []*http.Cookie{s.Cookie()})
+	}
+}
+
+// Save 将 cookie 以 JSON 格式导出到文件, 文件权限为 0600
+func (j *CookieJar) Save(filename string) error {
+	b, err := json.MarshalIndent(j.Export(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal cookies err %w", err)
+	}
+	return ioutil.WriteFile(filename, b, 0600)
+}
+
+// Load 从 Save 导出的文件中导入 cookie, 文件不存在时返回的 error 满足 os.IsNotExist(err)
+func (j *CookieJar) Load(filename string) error {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	var cookies []*SavedCookie
+	if err = json.Unmarshal(b, &cookies); err != nil {
+		return fmt.Errorf("unmarshal cookies err %w", err)
+	}
+	j.Import(cookies)
+	return nil
+}
+
+// saved 获取满足 filter 且未过期的 cookie 副本
+func (j *CookieJar) saved(filter func(*SavedCookie) bool) []*SavedCookie {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	now := time.Now()
+	cookies := make([]*SavedCookie, 0, len(j.entries))
+	for _, s := range j.entries {
+		if !s.expired(now) && filter(s) {
+			c := *s
+			cookies = append(cookies, &c)
+		}
+	}
+	sort.Slice(cookies, func(a, b int) bool {
+		return cookies[a].key() < cookies[b].key()
+	})
+	return cookies
+}
+
+// rebuild cookiejar 不支持删除单个 cookie, 使用保留的 cookie 重建, 调用方需持有锁
+func (j *CookieJar) rebuild() {
+	j.jar = newCookieJar()
+	now := time.Now()
+	for k, s := range j.entries {
+		if s.expired(now) {
+			delete(j.entries, k)
+			continue
+		}
+		j.jar.SetCookies(s.url(), []*http.Cookie{s.Cookie()})
+	}
+}
+
+// url 重新写入 cookiejar 时使用的来源地址
+func (s *SavedCookie) url() *url.URL {
+	scheme := "http"
+	if s.Secure {
+		scheme = "https"
+	}
+	return &url.URL{Scheme: scheme, Host: s.Domain, Path: s.Path}
+}
+
+// newSavedCookie 依据 cookiejar 的规则计算 cookie 的 domain、path 与过期时间, cookiejar 会拒绝的 cookie 返回 false
+func newSavedCookie(u *url.URL, c *http.Cookie, now time.Time) (*SavedCookie, bool) {
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return nil, false
+	}
+
+	s := &SavedCookie{
+		Name:     c.Name,
+		Value:    c.Value,
+		Domain:   host,
+		HostOnly: true,
+		Path:     c.Path,
+		Secure:   c.Secure,
+		HttpOnly: c.HttpOnly,
+		SameSite: c.SameSite,
+	}
+
+	if domain := strings.TrimPrefix(strings.ToLower(c.Domain), "."); domain != "" && domain != host {
+		if net.ParseIP(host) != nil || !strings.HasSuffix(host, "."+domain) {
+			return nil, false
+		}
+		if ps, _ := publicsuffix.PublicSuffix(domain); ps == domain {
+			return nil, false
+		}
+		s.Domain = domain
+		s.HostOnly = false
+	} else if domain != "" && net.ParseIP(host) == nil {
+		s.HostOnly = false
+	}
+
+	if s.Path == "" || s.Path[0] != '/' {
+		s.Path = "/"
+		if i := strings.LastIndex(u.Path, "/"); i > 0 {
+			s.Path = u.Path[:i]
+		}
+	}
+
+	switch {
+	case c.MaxAge < 0:
+		s.Expires = now.Add(-time.Second)
+	case c.MaxAge > 0:
+		s.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+	case !c.Expires.IsZero():
+		s.Expires = c.Expires
+		if !s.Expires.After(now) {
+			s.Expires = now.Add(-time.Second)
+		}
+	}
+	return s, true
+}
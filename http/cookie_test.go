@@ -0,0 +1,112 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestClientSession(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", TextPlain)
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1", Path: "/", HttpOnly: true})
+			http.SetCookie(w, &http.Cookie{Name: "remember", Value: "r1", Path: "/", MaxAge: 3600})
+		case "/logout":
+			http.SetCookie(w, &http.Cookie{Name: "remember", Value: "", Path: "/", MaxAge: -1})
+		default:
+			c, err := r.Cookie("session")
+			if err != nil {
+				w.Write([]byte("anonymous"))
+				return
+			}
+			w.Write([]byte(c.Value))
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(WithSession())
+	if got := doText(t, c, srv.URL+"/me"); got != "anonymous" {
+		t.Fatalf("before login %q", got)
+	}
+	doText(t, c, srv.URL+"/login")
+	if got := doText(t, c, srv.URL+"/me"); got != "s1" {
+		t.Fatalf("after login %q", got)
+	}
+
+	cookies := c.CookieJar().DomainCookies("127.0.0.1")
+	if len(cookies) != 2 || cookies[0].Name != "remember" || cookies[0].Expires.IsZero() ||
+		cookies[1].Name != "session" || !cookies[1].HttpOnly || !cookies[1].HostOnly {
+		t.Fatalf("domain cookies %+v", cookies)
+	}
+
+	// 导出到文件后在新的 client 中恢复会话
+	file := filepath.Join(t.TempDir(), "cookies.json")
+	if err := c.CookieJar().Save(file); err != nil {
+		t.Fatal(err)
+	}
+	c2 := NewClient(WithSession())
+	if err := c2.CookieJar().Load(file); err != nil {
+		t.Fatal(err)
+	}
+	if got := doText(t, c2, srv.URL+"/me"); got != "s1" {
+		t.Fatalf("after load %q", got)
+	}
+
+	doText(t, c2, srv.URL+"/logout")
+	if cookies := c2.CookieJar().Export(); len(cookies) != 1 || cookies[0].Name != "session" {
+		t.Fatalf("cookies after logout %+v", cookies)
+	}
+
+	c2.CookieJar().ClearDomain("127.0.0.1")
+	if got := doText(t, c2, srv.URL+"/me"); got != "anonymous" {
+		t.Fatalf("after clear %q", got)
+	}
+
+	if err := NewCookieJar().Load(filepath.Join(t.TempDir(), "missing.json")); !os.IsNotExist(err) {
+		t.Fatalf("expect not exist error, got %v", err)
+	}
+	if NewClient().CookieJar() != nil {
+		t.Fatal("client without session should not have a cookie jar")
+	}
+}
+
+func TestCookieJarDomain(t *testing.T) {
+	jar := NewCookieJar()
+	u, _ := url.Parse("https://www.example.com/a/b")
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "domain", Value: "1", Domain: ".example.com"},
+		{Name: "host", Value: "2"},
+		{Name: "suffix", Value: "3", Domain: "com"},
+		{Name: "other", Value: "4", Domain: "other.com"},
+	})
+
+	cookies := jar.DomainCookies("example.com")
+	if len(cookies) != 2 {
+		t.Fatalf("domain cookies %+v", cookies)
+	}
+	if cookies[0].Name != "domain" || cookies[0].Domain != "example.com" || cookies[0].HostOnly || cookies[0].Path != "/a" {
+		t.Fatalf("domain cookie %+v", cookies[0])
+	}
+	if cookies[1].Name != "host" || cookies[1].Domain != "www.example.com" || !cookies[1].HostOnly {
+		t.Fatalf("host cookie %+v", cookies[1])
+	}
+
+	api, _ := url.Parse("https://api.example.com/a")
+	if got := jar.Cookies(api); len(got) != 1 || got[0].Name != "domain" {
+		t.Fatalf("cookies for api %+v", got)
+	}
+
+	jar.ClearDomain("www.example.com")
+	if got := jar.Cookies(u); len(got) != 1 || got[0].Name != "domain" {
+		t.Fatalf("cookies after clear %+v", got)
+	}
+	jar.Clear()
+	if got := jar.Cookies(api); len(got) != 0 {
+		t.Fatalf("cookies after clear all %+v", got)
+	}
+}
@@ -0,0 +1,253 @@
+package http
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// DigestAuth HTTP Digest 鉴权(RFC 7616), 支持 MD5、SHA-256 及其 -sess 算法与 qop=auth
+// 首次请求收到 401 质询后计算应答并重新发送, 之后同一 host 的请求复用质询, nonce 计数递增
+// 服务端返回 stale=true 或新的质询时使用新的 nonce 重新发送, 每次请求最多重新发送一次
+func DigestAuth(username string, password string) AuthProvider {
+	return &digestAuth{
+		username:   username,
+		password:   password,
+		challenges: make(map[string]*digestChallenge),
+	}
+}
+
+type digestAuth struct {
+	username string
+	password string
+
+	lock       sync.Mutex
+	challenges map[string]*digestChallenge // key 为 host
+}
+
+// digestChallenge 服务端的 Digest 质询
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string // 为空表示服务端未要求 qop(RFC 2069)
+	nc        uint32 // 已使用的 nonce 计数
+}
+
+func (d *digestAuth) Wrap(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if !authAllowed(req) {
+			return next.RoundTrip(req)
+		}
+
+		host := req.URL.Host
+		sent := false
+		if authorization, ok := d.authorization(host, req); ok {
+			r := req.Clone(req.Context())
+			r.Header.Set("Authorization", authorization)
+			req, sent = r, true
+		}
+
+		resp, err := next.RoundTrip(req)
+		if err != nil || resp.StatusCode != http.StatusUnauthorized {
+			return resp, err
+		}
+
+		c, ok := parseDigestChallenge(resp.Header.Values("WWW-Authenticate"))
+		if !ok || (sent && !c.stale && !d.changed(host, c)) {
+			// 非 Digest 质询或凭据错误
+			return resp, nil
+		}
+		if c.qop == "" && c.qopOffered {
+			// 仅支持 auth-int 等不支持的 qop
+			return resp, nil
+		}
+
+		retry, ok := rewindRequest(req)
+		if !ok {
+			return resp, nil
+		}
+		drainBody(resp.Body)
+
+		d.lock.Lock()
+		d.challenges[host] = &c.digestChallenge
+		d.lock.Unlock()
+
+		authorization, _ := d.authorization(host, retry)
+		retry = retry.Clone(retry.Context())
+		retry.Header.Set("Authorization", authorization)
+		return next.RoundTrip(retry)
+	})
+}
+
+// changed 质询的 nonce 是否与已保存的不同
+func (d *digestAuth) changed(host string, c *parsedDigestChallenge) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	old, ok := d.challenges[host]
+	return !ok || old.nonce != c.nonce
+}
+
+// authorization 使用 host 已保存的质询计算 Authorization 请求头, 未收到过质询时返回 false
+func (d *digestAuth) authorization(host string, req *http.Request) (string, bool) {
+	d.lock.Lock()
+	c, ok := d.challenges[host]
+	if !ok {
+		d.lock.Unlock()
+		return "", false
+	}
+	c.nc++
+	nc := c.nc
+	challenge := *c
+	d.lock.Unlock()
+
+	h := digestHash(challenge.algorithm)
+	if h == nil {
+		return "", false
+	}
+	sum := func(s string) string {
+		h.Reset()
+		h.Write([]byte(s))
+		return hex.EncodeToString(h.Sum(nil))
+	}
+
+	uri := req.URL.RequestURI()
+	ncValue := fmt.Sprintf("%08x", nc)
+	cnonce := newCnonce()
+
+	ha1 := sum(d.username + ":" + challenge.realm + ":" + d.password)
+	if strings.HasSuffix(strings.ToLower(challenge.algorithm), "-sess") {
+		ha1 = sum(ha1 + ":" + challenge.nonce + ":" + cnonce)
+	}
+	ha2 := sum(req.Method + ":" + uri)
+
+	var response string
+	if challenge.qop == "" {
+		response = sum(ha1 + ":" + challenge.nonce + ":" + ha2)
+	} else {
+		response = sum(ha1 + ":" + challenge.nonce + ":" + ncValue + ":" + cnonce + ":" + challenge.qop + ":" + ha2)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `Digest username=%q, realm=%q, nonce=%q, uri=%q, response=%q`,
+		d.username, challenge.realm, challenge.nonce, uri, response)
+	if challenge.algorithm != "" {
+		fmt.Fprintf(&b, ", algorithm=%s", challenge.algorithm)
+	}
+	if challenge.opaque != "" {
+		fmt.Fprintf(&b, ", opaque=%q", challenge.opaque)
+	}
+	if challenge.qop != "" {
+		fmt.Fprintf(&b, `, qop=%s, nc=%s, cnonce=%q`, challenge.qop, ncValue, cnonce)
+	}
+	return b.String(), true
+}
+
+// digestHash 依据算法选择摘要函数, 不支持的算法返回 nil
+func digestHash(algorithm string) hash.Hash {
+	switch strings.ToUpper(strings.TrimSuffix(strings.ToLower(algorithm), "-sess")) {
+	case "", "MD5":
+		return md5.New()
+	case "SHA-256":
+		return sha256.New()
+	}
+	return nil
+}
+
+func newCnonce() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// parsedDigestChallenge 解析后的质询
+type parsedDigestChallenge struct {
+	digestChallenge
+	stale      bool
+	qopOffered bool // 服务端是否声明了 qop
+}
+
+// parseDigestChallenge 从 WWW-Authenticate 响应头中解析 Digest 质询
+func parseDigestChallenge(values []string) (*parsedDigestChallenge, bool) {
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if len(v) < 7 || !strings.EqualFold(v[:7], "Digest ") {
+			continue
+		}
+
+		params := parseAuthParams(v[7:])
+		c := &parsedDigestChallenge{
+			digestChallenge: digestChallenge{
+				realm:     params["realm"],
+				nonce:     params["nonce"],
+				opaque:    params["opaque"],
+				algorithm: params["algorithm"],
+			},
+			stale: strings.EqualFold(params["stale"], "true"),
+		}
+		if c.nonce == "" || digestHash(c.algorithm) == nil {
+			continue
+		}
+		if qop, ok := params["qop"]; ok {
+			c.qopOffered = true
+			for _, q := range strings.Split(qop, ",") {
+				if strings.TrimSpace(q) == "auth" {
+					c.qop = "auth"
+				}
+			}
+		}
+		return c, true
+	}
+	return nil, false
+}
+
+// parseAuthParams 解析 key=value 或 key="quoted value" 形式的参数列表, key 转为小写
+func parseAuthParams(s string) map[string]string {
+	params := make(map[string]string)
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		if s == "" {
+			return params
+		}
+
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			return params
+		}
+		key := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = strings.TrimLeft(s[eq+1:], " \t")
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			var b strings.Builder
+			i := 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				b.WriteByte(s[i])
+			}
+			value = b.String()
+			if i < len(s) {
+				i++
+			}
+			s = s[i:]
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+			value = strings.TrimSpace(s[:end])
+			s = s[end:]
+		}
+		params[key] = value
+	}
+}
@@ -0,0 +1,131 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// maxErrorBodySize 错误中保留的响应 body 最大长度
+const maxErrorBodySize = 1024
+
+// ErrInvalidConfig client 配置错误, 如代理配置冲突
+var ErrInvalidConfig = errors.New("invalid client config")
+
+// ErrNoMarshalHandler Content-Type 没有对应的 MarshalHandler
+var ErrNoMarshalHandler = errors.New("no marshal handler for content type")
+
+// ErrInvalidTarget WithResponseBodyData 或 WithResponseErrorData 的参数不是非 nil 指针
+var ErrInvalidTarget = errors.New("response target must be a non-nil pointer")
+
+// TransportError 请求发送失败, 包括网络错误、超时与调用方取消
+type TransportError struct {
+	Method string
+	URL    string
+	Err    error
+}
+
+func (e *TransportError) Error() string {
+	return fmt.Sprintf("do request %s %s err %v", e.Method, e.URL, e.Err)
+}
+
+// Unwrap ...
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+// ReadBodyError 读取响应 body 失败
+type ReadBodyError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Err        error
+}
+
+func (e *ReadBodyError) Error() string {
+	return fmt.Sprintf("read body %s %s status %d err %v", e.Method, e.URL, e.StatusCode, e.Err)
+}
+
+// Unwrap ...
+func (e *ReadBodyError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeError 依据 Content-Type 解析响应 body 失败
+type DecodeError struct {
+	Method      string
+	URL         string
+	StatusCode  int
+	ContentType string
+	Body        []byte // 响应 body, 超过 1KB 时截断
+	Err         error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("decode body %s %s status %d content type %q err %v body %s",
+		e.Method, e.URL, e.StatusCode, e.ContentType, e.Err, e.Body)
+}
+
+// Unwrap ...
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// StatusError 响应状态码不是 2xx, 通过 WithStatusCheck、WithResponseStatusCheck 或 WithResponseErrorData 开启
+type StatusError struct {
+	StatusCode int
+	Status     string
+	Method     string
+	URL        string
+	Body       []byte      // 响应 body, 超过 1KB 时截断
+	Payload    interface{} // WithResponseErrorData 配置的错误响应数据, 已完成解析
+	Err        error       // 解析 WithResponseErrorData 失败时为 *DecodeError
+}
+
+func newStatusError(req *http.Request, resp *http.Response, body []byte, payload interface{}) *StatusError {
+	return &StatusError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Method:     req.Method,
+		URL:        req.URL.String(),
+		Body:       truncateBody(body),
+		Payload:    payload,
+	}
+}
+
+func (e *StatusError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("response status %s %s %s body %s, decode error data err %v", e.Status, e.Method, e.URL, e.Body, e.Err)
+	}
+	return fmt.Sprintf("response status %s %s %s body %s", e.Status, e.Method, e.URL, e.Body)
+}
+
+// Unwrap ...
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+// truncateBody 截断 body 用于错误信息
+func truncateBody(body []byte) []byte {
+	if len(body) > maxErrorBodySize {
+		body = body[:maxErrorBodySize]
+	}
+	return append([]byte(nil), body...)
+}
+
+// IsCanceled 判断 err 是否由调用方取消 context 导致
+func IsCanceled(err error) bool {
+	return errors.Is(err, context.Canceled)
+}
+
+// IsTimeout 判断 err 是否由超时导致, 包括 context 超时、WithTimeout 以及 WithRequestTimeout
+func IsTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
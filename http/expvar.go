@@ -0,0 +1,97 @@
+package http
+
+import (
+	"expvar"
+	"sort"
+	"strings"
+)
+
+// ExpvarMetrics 通过 expvar 发布指标的 MetricsSink, 可在 /debug/vars 中查看
+// 发布的 expvar.Map 包含以下子 Map, key 为 "method host route", requests 与耗时的 key 追加状态码分类:
+//
+//	requests          请求数, key 如 "GET api.example.com /users/{id} 2xx"
+//	duration_seconds  请求耗时之和, key 与 requests 相同, 除以对应的请求数得到平均耗时
+//	duration_bucket   耗时不超过 le 秒的累计请求数, key 如 "GET api.example.com /users/{id} 2xx 0.1", 总数即 requests
+//	in_flight         进行中的请求数
+//	retries           重试次数
+//	request_bytes     发送的请求 body 字节数
+//	response_bytes    接收的响应 body 字节数
+//
+// expvar 没有直方图类型, 分桶以独立的 key 发布, 需要自行计算分位数; 计数只增不减, 标签过多时 key 的数量随之增长
+type ExpvarMetrics struct {
+	vars    *expvar.Map
+	buckets []float64
+
+	requests      *expvar.Map
+	duration      *expvar.Map
+	durationLE    *expvar.Map
+	inFlight      *expvar.Map
+	retries       *expvar.Map
+	requestBytes  *expvar.Map
+	responseBytes *expvar.Map
+}
+
+// NewExpvarMetrics 创建 ExpvarMetrics 并以 name 发布, 与 expvar.Publish 一致, name 重复时 panic
+// buckets 为耗时分桶, 单位秒, 为空时使用 DefaultLatencyBuckets
+func NewExpvarMetrics(name string, buckets ...float64) *ExpvarMetrics {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	b := append([]float64(nil), buckets...)
+	sort.Float64s(b)
+
+	m := &ExpvarMetrics{
+		vars:          expvar.NewMap(name),
+		buckets:       b,
+		requests:      new(expvar.Map),
+		duration:      new(expvar.Map),
+		durationLE:    new(expvar.Map),
+		inFlight:      new(expvar.Map),
+		retries:       new(expvar.Map),
+		requestBytes:  new(expvar.Map),
+		responseBytes: new(expvar.Map),
+	}
+	m.vars.Set("requests", m.requests)
+	m.vars.Set("duration_seconds", m.duration)
+	m.vars.Set("duration_bucket", m.durationLE)
+	m.vars.Set("in_flight", m.inFlight)
+	m.vars.Set("retries", m.retries)
+	m.vars.Set("request_bytes", m.requestBytes)
+	m.vars.Set("response_bytes", m.responseBytes)
+	return m
+}
+
+// Map 获取发布的 expvar.Map
+func (m *ExpvarMetrics) Map() *expvar.Map {
+	return m.vars
+}
+
+func expvarKey(labels MetricLabels) string {
+	return strings.Join([]string{labels.Method, labels.Host, labels.Route}, " ")
+}
+
+// InFlight ...
+func (m *ExpvarMetrics) InFlight(labels MetricLabels, delta int) {
+	m.inFlight.Add(expvarKey(labels), int64(delta))
+}
+
+// ObserveRequest ...
+func (m *ExpvarMetrics) ObserveRequest(r RequestMetric) {
+	key := expvarKey(r.MetricLabels)
+	classKey := key + " " + r.StatusClass()
+	seconds := r.Duration.Seconds()
+	m.requests.Add(classKey, 1)
+	m.duration.AddFloat(classKey, seconds)
+	for _, le := range m.buckets {
+		if seconds <= le {
+			m.durationLE.Add(classKey+" "+formatFloat(le), 1)
+		}
+	}
+	m.requestBytes.Add(key, r.BytesSent)
+	m.responseBytes.Add(key, r.BytesReceived)
+}
+
+// ObserveRetry ...
+func (m *ExpvarMetrics) ObserveRetry(labels MetricLabels) {
+	m.retries.Add(expvarKey(labels), 1)
+}
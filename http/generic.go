@@ -0,0 +1,67 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Do 执行请求并将响应 body 解析为 T, 非 2xx 响应返回 *StatusError, 同时返回 Response
+// 错误的分类同 Client.Do; 配置了 WithResponseErrorData 时 4xx/5xx 响应解析到错误数据
+// 响应 body 为空时返回 T 的零值, 常见于 DELETE、PUT、PATCH 等请求
+// 不能与 WithResponseBody、WithResponseBodyReader、WithResponseStream、WithResponseWriter、WithResumeFile 同时使用
+//
+//	user, resp, err := Do[User](c, req, WithRequestTimeout(time.Second))
+func Do[T any](c *Client, req *http.Request, opts ...DoOptions) (T, *Response, error) {
+	var data T
+
+	var opt doOptions
+	opt.ExecuteOptions(opts)
+	if opt.response != nil || opt.responseReader != nil || opt.stream != nil || opt.resumeFile != "" {
+		return data, nil, fmt.Errorf("do[T] can not be used with WithResponseBody, WithResponseBodyReader or streaming options")
+	}
+
+	opts = append(opts[:len(opts):len(opts)], WithResponseStatusCheck(true), WithResponseBodyData(&data), withEmptyBody())
+	resp, err := c.Do(req, opts...)
+	return data, resp, err
+}
+
+// withEmptyBody 响应 body 为空时不解析 WithResponseBodyData 配置的数据
+func withEmptyBody() DoOptions {
+	return func(o *doOptions) {
+		o.emptyBody = true
+	}
+}
+
+// Get 发送 GET 请求并将响应 body 解析为 T, 需要 DoOptions 时使用 NewRequest 与 Do
+func Get[T any](c *Client, rawURL string, opts ...RequestOptions) (T, *Response, error) {
+	return send[T](c, http.MethodGet, rawURL, opts)
+}
+
+// Delete 发送 DELETE 请求并将响应 body 解析为 T
+func Delete[T any](c *Client, rawURL string, opts ...RequestOptions) (T, *Response, error) {
+	return send[T](c, http.MethodDelete, rawURL, opts)
+}
+
+// Post 以 WithContentType 对应的 MarshalHandler(默认 JSON)序列化 body 发送 POST 请求, 并将响应 body 解析为 Resp
+func Post[Req any, Resp any](c *Client, rawURL string, body Req, opts ...RequestOptions) (Resp, *Response, error) {
+	return send[Resp](c, http.MethodPost, rawURL, append([]RequestOptions{WithBody(body)}, opts...))
+}
+
+// Put 发送 PUT 请求, 同 Post
+func Put[Req any, Resp any](c *Client, rawURL string, body Req, opts ...RequestOptions) (Resp, *Response, error) {
+	return send[Resp](c, http.MethodPut, rawURL, append([]RequestOptions{WithBody(body)}, opts...))
+}
+
+// Patch 发送 PATCH 请求, 同 Post
+func Patch[Req any, Resp any](c *Client, rawURL string, body Req, opts ...RequestOptions) (Resp, *Response, error) {
+	return send[Resp](c, http.MethodPatch, rawURL, append([]RequestOptions{WithBody(body)}, opts...))
+}
+
+func send[T any](c *Client, method string, rawURL string, opts []RequestOptions) (T, *Response, error) {
+	req, err := c.NewRequest(method, append([]RequestOptions{WithURL(rawURL)}, opts...)...)
+	if err != nil {
+		var zero T
+		return zero, nil, err
+	}
+	return Do[T](c, req)
+}
@@ -0,0 +1,97 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGenericHelpers(t *testing.T) {
+	type user struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ApplicationJSON)
+		switch {
+		case r.URL.Path == "/empty":
+			w.WriteHeader(http.StatusOK)
+		case r.URL.Path == "/missing":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":"not found"}`))
+		case r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodGet:
+			json.NewEncoder(w).Encode(user{ID: 1, Name: "get"})
+		default:
+			var u user
+			json.NewDecoder(r.Body).Decode(&u)
+			u.Name = r.Method + " " + u.Name
+			json.NewEncoder(w).Encode(u)
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(WithBaseURL(srv.URL))
+
+	u, resp, err := Get[user](c, "/users/{id}", WithPathParam("id", "1"))
+	if err != nil || !resp.IsOK() || u != (user{ID: 1, Name: "get"}) {
+		t.Fatalf("Get %+v %v", u, err)
+	}
+	if u, _, err = Post[user, user](c, "/users", user{ID: 2, Name: "a"}); err != nil || u.Name != "POST a" {
+		t.Fatalf("Post %+v %v", u, err)
+	}
+	if p, _, err := Put[*user, *user](c, "/users/2", &user{ID: 2, Name: "b"}); err != nil || p.Name != "PUT b" {
+		t.Fatalf("Put %+v %v", p, err)
+	}
+	if m, _, err := Patch[map[string]string, map[string]interface{}](c, "/users/2", map[string]string{"name": "c"}); err != nil || m["name"] != "PATCH c" {
+		t.Fatalf("Patch %+v %v", m, err)
+	}
+	if _, resp, err := Delete[struct{}](c, "/users/2"); err != nil || resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("Delete %v", err)
+	}
+
+	// 非 2xx 响应返回 StatusError
+	_, resp, err = Get[user](c, "/missing")
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || resp == nil || resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expect StatusError, got %v", err)
+	}
+
+	req, _ := c.NewRequest(http.MethodGet, WithURL("/users/1"))
+	if u, _, err = Do[user](c, req); err != nil || u.ID != 1 {
+		t.Fatalf("Do %+v %v", u, err)
+	}
+
+	// 空 body 的 2xx 响应返回零值
+	if _, resp, err := Delete[struct{}](c, "/empty"); err != nil || resp == nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("Delete empty body %v", err)
+	}
+	if u, resp, err = Put[user, user](c, "/empty", user{ID: 3}); err != nil || resp == nil || u != (user{}) {
+		t.Fatalf("Put empty body %+v %v", u, err)
+	}
+
+	// 不解析 body 的选项不能与 Do[T] 同时使用
+	var b []byte
+	req, _ = c.NewRequest(http.MethodGet, WithURL("/users/1"))
+	if _, _, err = Do[user](c, req, WithResponseBody(&b)); err == nil {
+		t.Fatal("Do[T] with WithResponseBody should return error")
+	}
+}
+
+func TestClientInvalidTarget(t *testing.T) {
+	c := NewClient()
+	req, _ := c.NewRequest(http.MethodGet, WithURL("http://127.0.0.1:1"))
+
+	var data map[string]interface{}
+	if _, err := c.Do(req, WithResponseBodyData(data)); !errors.Is(err, ErrInvalidTarget) {
+		t.Fatalf("non-pointer target: expect ErrInvalidTarget, got %v", err)
+	}
+	var p *map[string]interface{}
+	if _, err := c.Do(req, WithResponseErrorData(p)); !errors.Is(err, ErrInvalidTarget) {
+		t.Fatalf("nil pointer target: expect ErrInvalidTarget, got %v", err)
+	}
+}
@@ -0,0 +1,282 @@
+// Package httpmock 为基于 http.Client 的代码提供测试桩
+//
+// Server 基于 httptest.Server, 适用于需要真实网络地址的场景; Transport 为 http.RoundTripper, 不监听端口
+// 两者使用相同的链式 API 配置期望与响应, 测试结束时检查未满足的期望, 并记录所有请求供断言:
+//
+//	srv := httpmock.NewServer(t)
+//	srv.Expect(http.MethodPost, "/users").
+//		WithHeader("Authorization", "Bearer token").
+//		WithJSONBody(map[string]string{"name": "a"}).
+//		RespondJSON(http.StatusCreated, map[string]int{"id": 1})
+//
+//	c := http.NewClient(http.WithBaseURL(srv.URL))
+//
+// Recorder 录制真实请求与响应到 cassette 文件并在之后回放, 用于在没有网络的 CI 中运行依赖外部接口的集成测试
+package httpmock
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// ErrUnexpectedRequest 请求没有匹配的期望, Transport 返回的 error 满足 errors.Is(err, ErrUnexpectedRequest)
+var ErrUnexpectedRequest = errors.New("httpmock: unexpected request")
+
+// TestingT *testing.T 中 httpmock 使用的方法
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+	Cleanup(f func())
+}
+
+// Request 记录的请求
+type Request struct {
+	Method string
+	URL    *url.URL
+	Header http.Header
+	Body   []byte
+}
+
+// JSON 将请求 body 解析到 v
+func (r *Request) JSON(v interface{}) error {
+	return json.Unmarshal(r.Body, v)
+}
+
+// Expectation 请求期望与对应的响应, 通过 Expect 创建, 需在发送请求前配置完成
+// 默认期望匹配 1 次, 响应 200 与空 body
+type Expectation struct {
+	method string
+	path   string
+	query  url.Values
+	header http.Header
+
+	body     []byte
+	jsonBody interface{}
+
+	times int // 小于 0 时不限次数
+	calls int
+
+	status     int
+	respHeader http.Header
+	respBody   []byte
+	delay      time.Duration
+	err        error
+}
+
+// WithQuery 期望请求包含 query 参数 key=value, 可多次配置
+func (e *Expectation) WithQuery(key string, value string) *Expectation {
+	if e.query == nil {
+		e.query = make(url.Values)
+	}
+	e.query.Add(key, value)
+	return e
+}
+
+// WithHeader 期望请求头 key 包含 value, 可多次配置
+func (e *Expectation) WithHeader(key string, value string) *Expectation {
+	if e.header == nil {
+		e.header = make(http.Header)
+	}
+	e.header.Add(key, value)
+	return e
+}
+
+// WithBody 期望请求 body 与 body 完全一致
+func (e *Expectation) WithBody(body string) *Expectation {
+	e.body = []byte(body)
+	return e
+}
+
+// WithJSONBody 期望请求 body 为 JSON, 且与 v 序列化后的 JSON 语义一致(忽略字段顺序与空白)
+func (e *Expectation) WithJSONBody(v interface{}) *Expectation {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("httpmock: marshal expected body err %v", err))
+	}
+	if err = json.Unmarshal(b, &e.jsonBody); err != nil {
+		panic(fmt.Sprintf("httpmock: unmarshal expected body err %v", err))
+	}
+	return e
+}
+
+// Times 期望匹配 n 次
+func (e *Expectation) Times(n int) *Expectation {
+	e.times = n
+	return e
+}
+
+// AnyTimes 不限匹配次数, 未被调用也不报错
+func (e *Expectation) AnyTimes() *Expectation {
+	e.times = -1
+	return e
+}
+
+// Respond 以 status 与 body 响应
+func (e *Expectation) Respond(status int, body string) *Expectation {
+	e.status = status
+	e.respBody = []byte(body)
+	return e
+}
+
+// RespondJSON 以 status 与 v 序列化后的 JSON 响应, 并设置 Content-Type: application/json
+func (e *Expectation) RespondJSON(status int, v interface{}) *Expectation {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("httpmock: marshal response body err %v", err))
+	}
+	e.status = status
+	e.respBody = b
+	return e.RespondHeader("Content-Type", "application/json")
+}
+
+// RespondHeader 设置响应头
+func (e *Expectation) RespondHeader(key string, value string) *Expectation {
+	if e.respHeader == nil {
+		e.respHeader = make(http.Header)
+	}
+	e.respHeader.Set(key, value)
+	return e
+}
+
+// Delay 延迟 d 后响应, 请求的 context 取消时提前结束
+func (e *Expectation) Delay(d time.Duration) *Expectation {
+	e.delay = d
+	return e
+}
+
+// RespondError 模拟网络错误: Transport 返回 err, Server 直接关闭连接
+func (e *Expectation) RespondError(err error) *Expectation {
+	e.err = err
+	return e
+}
+
+func (e *Expectation) String() string {
+	return e.method + " " + e.path
+}
+
+// match 请求是否满足期望
+func (e *Expectation) match(req *http.Request, body []byte) bool {
+	path := req.URL.Path
+	if path == "" {
+		// 与发送时一致, 空路径视为 "/"
+		path = "/"
+	}
+	if e.method != req.Method || e.path != path {
+		return false
+	}
+
+	query := req.URL.Query()
+	for k, vs := range e.query {
+		for _, v := range vs {
+			if !contains(query[k], v) {
+				return false
+			}
+		}
+	}
+	for k, vs := range e.header {
+		for _, v := range vs {
+			if !contains(req.Header.Values(k), v) {
+				return false
+			}
+		}
+	}
+
+	if e.body != nil && !bytes.Equal(e.body, body) {
+		return false
+	}
+	if e.jsonBody != nil {
+		var actual interface{}
+		if err := json.Unmarshal(body, &actual); err != nil || !reflect.DeepEqual(e.jsonBody, actual) {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// mock Server 与 Transport 共用的期望匹配与请求记录
+type mock struct {
+	t TestingT
+
+	lock         sync.Mutex
+	expectations []*Expectation
+	requests     []*Request
+}
+
+func newMock(t TestingT) *mock {
+	return &mock{t: t}
+}
+
+// Expect 添加对 method 与 path 的请求期望, 多个期望都满足时按添加顺序匹配
+func (m *mock) Expect(method string, path string) *Expectation {
+	e := &Expectation{method: method, path: path, times: 1, status: http.StatusOK}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.expectations = append(m.expectations, e)
+	return e
+}
+
+// Requests 获取已记录的请求(包括未匹配期望的请求), 按接收顺序排列
+func (m *mock) Requests() []*Request {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return append([]*Request(nil), m.requests...)
+}
+
+// AssertExpectations 检查所有期望是否满足匹配次数, 未满足时通过 t.Errorf 报告, 测试结束时自动调用
+func (m *mock) AssertExpectations() bool {
+	m.t.Helper()
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	ok := true
+	for _, e := range m.expectations {
+		if e.times >= 0 && e.calls != e.times {
+			m.t.Errorf("httpmock: expected %s to be called %d times, got %d", e, e.times, e.calls)
+			ok = false
+		}
+	}
+	return ok
+}
+
+// handle 记录请求并查找匹配的期望, 没有匹配的期望时通过 t.Errorf 报告并返回 nil
+func (m *mock) handle(req *http.Request) *Expectation {
+	var body []byte
+	if req.Body != nil {
+		body, _ = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+	}
+	u := *req.URL
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.requests = append(m.requests, &Request{Method: req.Method, URL: &u, Header: req.Header.Clone(), Body: body})
+	for _, e := range m.expectations {
+		if (e.times < 0 || e.calls < e.times) && e.match(req, body) {
+			e.calls++
+			return e
+		}
+	}
+	m.t.Errorf("httpmock: unexpected request %s %s", req.Method, req.URL)
+	return nil
+}
@@ -0,0 +1,139 @@
+package httpmock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeT 记录错误与 Cleanup 函数, 用于检查 httpmock 报告的错误
+type fakeT struct {
+	errors   []string
+	cleanups []func()
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func (t *fakeT) Cleanup(f func()) {
+	t.cleanups = append(t.cleanups, f)
+}
+
+func (t *fakeT) finish() {
+	for i := len(t.cleanups) - 1; i >= 0; i-- {
+		t.cleanups[i]()
+	}
+}
+
+func do(t *testing.T, hc *http.Client, method string, url string, body string, header http.Header) (*http.Response, string) {
+	t.Helper()
+
+	req, _ := http.NewRequest(method, url, strings.NewReader(body))
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, _ := ioutil.ReadAll(resp.Body)
+	return resp, string(b)
+}
+
+func TestServer(t *testing.T) {
+	ft := &fakeT{}
+	srv := NewServer(ft)
+	srv.Expect(http.MethodPost, "/users").
+		WithQuery("dry", "1").
+		WithHeader("Authorization", "Bearer token").
+		WithJSONBody(map[string]interface{}{"name": "a", "age": 1}).
+		RespondJSON(http.StatusCreated, map[string]int{"id": 1})
+	srv.Expect(http.MethodGet, "/users/1").Times(2).Respond(http.StatusOK, "user")
+	srv.Expect(http.MethodDelete, "/users/1")
+
+	hc := srv.Client()
+	resp, body := do(t, hc, http.MethodPost, srv.URL+"/users?dry=1", `{"age": 1, "name": "a"}`, http.Header{"Authorization": {"Bearer token"}})
+	if resp.StatusCode != http.StatusCreated || body != `{"id":1}` || resp.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("post %d %q", resp.StatusCode, body)
+	}
+	for i := 0; i < 3; i++ {
+		resp, body = do(t, hc, http.MethodGet, srv.URL+"/users/1", "", nil)
+	}
+	if resp.StatusCode != http.StatusNotImplemented {
+		t.Fatalf("third get should be unexpected, got %d %q", resp.StatusCode, body)
+	}
+
+	reqs := srv.Requests()
+	if len(reqs) != 4 || reqs[0].URL.RawQuery != "dry=1" || reqs[0].Header.Get("Authorization") != "Bearer token" {
+		t.Fatalf("requests %+v", reqs)
+	}
+	var payload map[string]interface{}
+	if err := reqs[0].JSON(&payload); err != nil || payload["name"] != "a" {
+		t.Fatalf("request body %v %v", payload, err)
+	}
+
+	ft.finish()
+	if len(ft.errors) != 2 || !strings.Contains(ft.errors[0], "unexpected request GET") || !strings.Contains(ft.errors[1], "DELETE /users/1 to be called 1 times, got 0") {
+		t.Fatalf("errors %q", ft.errors)
+	}
+}
+
+func TestServerDelayAndError(t *testing.T) {
+	srv := NewServer(t)
+	srv.Expect(http.MethodGet, "/slow").Delay(time.Second)
+	srv.Expect(http.MethodGet, "/reset").RespondError(errors.New("reset"))
+
+	hc := &http.Client{Timeout: 50 * time.Millisecond}
+	if _, err := hc.Get(srv.URL + "/slow"); err == nil {
+		t.Fatal("delayed response should time out")
+	}
+	if _, err := hc.Get(srv.URL + "/reset"); err == nil {
+		t.Fatal("closed connection should return error")
+	}
+}
+
+func TestTransport(t *testing.T) {
+	ft := &fakeT{}
+	tr := NewTransport(ft)
+	tr.Expect(http.MethodPut, "/items").WithBody("raw").AnyTimes().Respond(http.StatusAccepted, "ok")
+	tr.Expect(http.MethodGet, "/slow").Delay(time.Second)
+	netErr := errors.New("connection refused")
+	tr.Expect(http.MethodGet, "/down").RespondError(netErr)
+
+	hc := &http.Client{Transport: tr}
+	for i := 0; i < 2; i++ {
+		if resp, body := do(t, hc, http.MethodPut, "http://example.com/items", "raw", nil); resp.StatusCode != http.StatusAccepted || body != "ok" {
+			t.Fatalf("put %d %q", resp.StatusCode, body)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://example.com/slow", nil)
+	if _, err := hc.Do(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expect deadline exceeded, got %v", err)
+	}
+	if _, err := hc.Get("http://example.com/down"); !errors.Is(err, netErr) {
+		t.Fatalf("expect %v, got %v", netErr, err)
+	}
+	if _, err := hc.Get("http://example.com/unknown"); !errors.Is(err, ErrUnexpectedRequest) {
+		t.Fatalf("expect ErrUnexpectedRequest, got %v", err)
+	}
+	if len(tr.Requests()) != 5 {
+		t.Fatalf("requests %d", len(tr.Requests()))
+	}
+
+	ft.finish()
+	if len(ft.errors) != 1 || !strings.Contains(ft.errors[0], "unexpected request GET") {
+		t.Fatalf("errors %q", ft.errors)
+	}
+}
@@ -0,0 +1,523 @@
+package httpmock
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// ErrInteractionNotFound 回放时 cassette 中没有匹配的记录, RoundTrip 返回的 error 满足 errors.Is(err, ErrInteractionNotFound)
+var ErrInteractionNotFound = errors.New("httpmock: interaction not found in cassette")
+
+// Redacted 脱敏后的值
+const Redacted = "REDACTED"
+
+// RecorderMode Recorder 的工作模式
+type RecorderMode int
+
+const (
+	// ModeReplay 只回放 cassette 中的记录, 不发送真实请求, 用于 CI
+	ModeReplay RecorderMode = iota
+	// ModeRecord 发送真实请求并重新录制, 覆盖已有的 cassette
+	ModeRecord
+	// ModeReplayOrRecord 优先回放, 没有匹配的记录时发送真实请求并追加到 cassette
+	ModeReplayOrRecord
+)
+
+// RecordedRequest cassette 中记录的请求, 已脱敏
+type RecordedRequest struct {
+	Method     string      `json:"method"`
+	URL        string      `json:"url"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+	BodyBase64 bool        `json:"body_base64,omitempty"` // body 不是 UTF-8 文本时以 base64 编码
+}
+
+// RecordedResponse cassette 中记录的响应, 已脱敏
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+	BodyBase64 bool        `json:"body_base64,omitempty"`
+}
+
+// Interaction 一次请求与响应
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// Cassette 录制的请求与响应, 以 JSON 格式保存
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// MatchFunc 判断请求 r 是否与记录的请求 recorded 匹配, r 已按 Recorder 的规则脱敏
+type MatchFunc func(r *RecordedRequest, recorded *RecordedRequest) bool
+
+// MatchMethod 匹配请求方法
+func MatchMethod(r *RecordedRequest, recorded *RecordedRequest) bool {
+	return r.Method == recorded.Method
+}
+
+// MatchURL 匹配完整 url, query 参数的顺序不影响匹配
+func MatchURL(r *RecordedRequest, recorded *RecordedRequest) bool {
+	u1, err1 := url.Parse(r.URL)
+	u2, err2 := url.Parse(recorded.URL)
+	if err1 != nil || err2 != nil {
+		return r.URL == recorded.URL
+	}
+	u1.RawQuery, u2.RawQuery = u1.Query().Encode(), u2.Query().Encode()
+	return u1.String() == u2.String()
+}
+
+// MatchBody 匹配请求 body
+func MatchBody(r *RecordedRequest, recorded *RecordedRequest) bool {
+	return r.Body == recorded.Body && r.BodyBase64 == recorded.BodyBase64
+}
+
+// MatchHeaders 匹配指定的请求头
+func MatchHeaders(keys ...string) MatchFunc {
+	return func(r *RecordedRequest, recorded *RecordedRequest) bool {
+		for _, k := range keys {
+			if strings.Join(r.Header.Values(k), ",") != strings.Join(recorded.Header.Values(k), ",") {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// MatchAll 所有规则都匹配时匹配
+func MatchAll(matchers ...MatchFunc) MatchFunc {
+	return func(r *RecordedRequest, recorded *RecordedRequest) bool {
+		for _, m := range matchers {
+			if !m(r, recorded) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// RecorderConfig Recorder 配置
+type RecorderConfig struct {
+	Cassette string       // cassette 文件路径
+	Mode     RecorderMode // 默认 ModeReplay
+
+	// Transport 发送真实请求的 Transport, 默认 http.DefaultTransport
+	// 录制 client 的请求时应传入 client 原有的 Transport, 保留 TLS、连接池、代理等配置
+	Transport http.RoundTripper
+
+	// Match 匹配规则, 默认 MatchAll(MatchMethod, MatchURL)
+	Match MatchFunc
+
+	// RedactHeaders 脱敏的请求头与响应头, 默认 Authorization、Proxy-Authorization、Cookie、Set-Cookie
+	RedactHeaders []string
+	// RedactFields 脱敏的 query 参数, 以及 JSON 与 application/x-www-form-urlencoded body 中的字段, 不区分大小写
+	// 默认 access_token、refresh_token、id_token、token、client_secret、password、api_key
+	RedactFields []string
+	// Redact 自定义脱敏, 在默认规则之后执行
+	// 录制时作用于完整的记录; 回放时同样作用于请求后再匹配, 此时 i.Response 为空
+	Redact func(i *Interaction)
+}
+
+var (
+	defaultRedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+	defaultRedactFields  = []string{"access_token", "refresh_token", "id_token", "token", "client_secret", "password", "api_key"}
+)
+
+// Recorder 录制与回放请求的 http.RoundTripper
+// 以 client 原有的 Transport 创建并替换, 录制时的真实请求仍使用 client 的 TLS、代理等配置:
+//
+//	rec, err := httpmock.NewRecorder(httpmock.RecorderConfig{Cassette: path, Mode: mode, Transport: c.HTTPClient.Transport})
+//	c.HTTPClient.Transport = rec
+//
+// 录制的数据在写入 cassette 前脱敏, 回放时请求按同样的规则脱敏后匹配; 每个记录优先回放一次, 全部回放过后可重复回放
+// gzip、deflate 编码的 body 解码后脱敏保存, 回放时返回未编码的 body; 其他编码的 body 无法脱敏, RoundTrip 返回错误
+type Recorder struct {
+	cfg    RecorderConfig
+	fields map[string]bool
+
+	lock     sync.Mutex
+	cassette Cassette
+	used     map[*Interaction]bool
+}
+
+// NewRecorder 创建 Recorder, ModeReplay 与 ModeReplayOrRecord 模式加载已有的 cassette
+// ModeReplay 模式下 cassette 不存在时返回错误
+func NewRecorder(cfg RecorderConfig) (*Recorder, error) {
+	if cfg.Cassette == "" {
+		return nil, fmt.Errorf("httpmock: cassette path is empty")
+	}
+	if cfg.Transport == nil {
+		cfg.Transport = http.DefaultTransport
+	}
+	if cfg.Match == nil {
+		cfg.Match = MatchAll(MatchMethod, MatchURL)
+	}
+	if cfg.RedactHeaders == nil {
+		cfg.RedactHeaders = defaultRedactHeaders
+	}
+	if cfg.RedactFields == nil {
+		cfg.RedactFields = defaultRedactFields
+	}
+
+	r := &Recorder{cfg: cfg, fields: make(map[string]bool, len(cfg.RedactFields)), used: make(map[*Interaction]bool)}
+	for _, f := range cfg.RedactFields {
+		r.fields[strings.ToLower(f)] = true
+	}
+
+	if cfg.Mode == ModeRecord {
+		return r, nil
+	}
+	b, err := ioutil.ReadFile(cfg.Cassette)
+	if err != nil {
+		if os.IsNotExist(err) && cfg.Mode == ModeReplayOrRecord {
+			return r, nil
+		}
+		return nil, fmt.Errorf("httpmock: read cassette err %w", err)
+	}
+	if err = json.Unmarshal(b, &r.cassette); err != nil {
+		return nil, fmt.Errorf("httpmock: decode cassette %s err %w", cfg.Cassette, err)
+	}
+	return r, nil
+}
+
+// Interactions 获取 cassette 中的记录
+func (r *Recorder) Interactions() []*Interaction {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return append([]*Interaction(nil), r.cassette.Interactions...)
+}
+
+// RoundTrip ...
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	recorded, err := r.recordRequest(req, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.cfg.Mode != ModeRecord {
+		if i := r.find(r.redactRequest(recorded)); i != nil {
+			return replay(req, i)
+		}
+		if r.cfg.Mode == ModeReplay {
+			return nil, fmt.Errorf("%w: %s %s", ErrInteractionNotFound, recorded.Method, recorded.URL)
+		}
+	}
+
+	// 发送真实请求, 不修改调用方的请求
+	outReq := req.Clone(req.Context())
+	outReq.Body = ioutil.NopCloser(bytes.NewReader(body))
+	outReq.GetBody = nil
+	resp, err := r.cfg.Transport.RoundTrip(outReq)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+	recordedResp, err := r.recordResponse(resp, respBody)
+	if err != nil {
+		return nil, err
+	}
+	i := &Interaction{Request: *recorded, Response: *recordedResp}
+	if r.cfg.Redact != nil {
+		r.cfg.Redact(i)
+	}
+	if err = r.add(i); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// find 查找匹配的记录, 优先返回未回放过的记录
+func (r *Recorder) find(req *RecordedRequest) *Interaction {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var found *Interaction
+	for _, i := range r.cassette.Interactions {
+		if !r.cfg.Match(req, &i.Request) {
+			continue
+		}
+		if !r.used[i] {
+			r.used[i] = true
+			return i
+		}
+		if found == nil {
+			found = i
+		}
+	}
+	return found
+}
+
+// add 追加记录并写入 cassette
+func (r *Recorder) add(i *Interaction) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.cassette.Interactions = append(r.cassette.Interactions, i)
+	r.used[i] = true
+
+	b, err := json.MarshalIndent(&r.cassette, "", "  ")
+	if err != nil {
+		return fmt.Errorf("httpmock: encode cassette err %w", err)
+	}
+	if err = os.MkdirAll(filepath.Dir(r.cfg.Cassette), 0755); err != nil {
+		return fmt.Errorf("httpmock: write cassette err %w", err)
+	}
+	// 先写临时文件再重命名, 避免中断时留下不完整的 cassette
+	tmp := r.cfg.Cassette + ".tmp"
+	if err = ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return fmt.Errorf("httpmock: write cassette err %w", err)
+	}
+	if err = os.Rename(tmp, r.cfg.Cassette); err != nil {
+		return fmt.Errorf("httpmock: write cassette err %w", err)
+	}
+	return nil
+}
+
+// replay 由记录生成响应
+func replay(req *http.Request, i *Interaction) (*http.Response, error) {
+	body, err := decodeBody(i.Response.Body, i.Response.BodyBase64)
+	if err != nil {
+		return nil, fmt.Errorf("httpmock: decode recorded body err %w", err)
+	}
+	header := i.Response.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	// 脱敏后 body 长度可能变化
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+
+	return &http.Response{
+		Status:        strconv.Itoa(i.Response.StatusCode) + " " + http.StatusText(i.Response.StatusCode),
+		StatusCode:    i.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// redactRequest 对请求执行自定义脱敏, 使回放时的请求与录制时写入 cassette 的请求一致
+func (r *Recorder) redactRequest(recorded *RecordedRequest) *RecordedRequest {
+	if r.cfg.Redact == nil {
+		return recorded
+	}
+	i := &Interaction{Request: *recorded}
+	i.Request.Header = recorded.Header.Clone()
+	r.cfg.Redact(i)
+	return &i.Request
+}
+
+func (r *Recorder) recordRequest(req *http.Request, body []byte) (*RecordedRequest, error) {
+	u := *req.URL
+	if query := u.Query(); r.redactValues(query) {
+		u.RawQuery = query.Encode()
+	}
+	header, body, err := decodeContent(req.Header, body)
+	if err != nil {
+		return nil, err
+	}
+	b, isBase64 := encodeBody(r.redactBody(header.Get("Content-Type"), body))
+	return &RecordedRequest{
+		Method:     req.Method,
+		URL:        u.String(),
+		Header:     r.redactHeader(header),
+		Body:       b,
+		BodyBase64: isBase64,
+	}, nil
+}
+
+func (r *Recorder) recordResponse(resp *http.Response, body []byte) (*RecordedResponse, error) {
+	header, body, err := decodeContent(resp.Header, body)
+	if err != nil {
+		return nil, err
+	}
+	b, isBase64 := encodeBody(r.redactBody(header.Get("Content-Type"), body))
+	return &RecordedResponse{
+		StatusCode: resp.StatusCode,
+		Header:     r.redactHeader(header),
+		Body:       b,
+		BodyBase64: isBase64,
+	}, nil
+}
+
+// decodeContent 解码 gzip、deflate 编码的 body, 使脱敏规则作用于编码前的内容, cassette 中保存解码后的 body
+// 返回的 header 删除了 Content-Encoding 与 Content-Length; 其他编码无法脱敏, 返回错误
+func decodeContent(header http.Header, body []byte) (http.Header, []byte, error) {
+	encoding := strings.ToLower(strings.TrimSpace(header.Get("Content-Encoding")))
+	if encoding == "" || encoding == "identity" || len(body) == 0 {
+		return header, body, nil
+	}
+
+	var (
+		zr  io.ReadCloser
+		err error
+	)
+	switch encoding {
+	case "gzip", "x-gzip":
+		zr, err = gzip.NewReader(bytes.NewReader(body))
+	case "deflate":
+		// HTTP 的 deflate 编码应为 zlib 格式, 部分服务端返回原始 deflate 数据
+		if zr, err = zlib.NewReader(bytes.NewReader(body)); err != nil {
+			zr, err = flate.NewReader(bytes.NewReader(body)), nil
+		}
+	default:
+		return nil, nil, fmt.Errorf("httpmock: can not redact body with Content-Encoding %q", encoding)
+	}
+	if err == nil {
+		body, err = ioutil.ReadAll(zr)
+		zr.Close()
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("httpmock: decode %s body err %w", encoding, err)
+	}
+
+	header = header.Clone()
+	header.Del("Content-Encoding")
+	header.Del("Content-Length")
+	return header, body, nil
+}
+
+func (r *Recorder) redactHeader(header http.Header) http.Header {
+	if len(header) == 0 {
+		return nil
+	}
+	h := header.Clone()
+	for _, k := range r.cfg.RedactHeaders {
+		if vs := h.Values(k); len(vs) > 0 {
+			h.Del(k)
+			for range vs {
+				h.Add(k, Redacted)
+			}
+		}
+	}
+	return h
+}
+
+// redactValues 脱敏 values 中的字段, 返回是否有字段被脱敏
+func (r *Recorder) redactValues(values url.Values) bool {
+	redacted := false
+	for k, vs := range values {
+		if r.fields[strings.ToLower(k)] {
+			for i := range vs {
+				vs[i] = Redacted
+			}
+			redacted = true
+		}
+	}
+	return redacted
+}
+
+// redactBody 脱敏 JSON 与 application/x-www-form-urlencoded body 中的字段, 其他类型的 body 不处理
+// 没有字段被脱敏时返回原始 body, 保留数字精度、字段顺序与转义
+func (r *Recorder) redactBody(contentType string, body []byte) []byte {
+	if len(body) == 0 || len(r.fields) == 0 {
+		return body
+	}
+	mt, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mt == "application/x-www-form-urlencoded":
+		values, err := url.ParseQuery(string(body))
+		if err != nil || !r.redactValues(values) {
+			return body
+		}
+		return []byte(values.Encode())
+	case mt == "application/json" || strings.HasSuffix(mt, "+json"):
+		// UseNumber 避免大整数转为 float64 后丢失精度
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			return body
+		}
+		if _, err := dec.Token(); err != io.EOF {
+			return body
+		}
+		if !r.redactJSON(v) {
+			return body
+		}
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(v); err != nil {
+			return body
+		}
+		return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+	}
+	return body
+}
+
+// redactJSON 原地脱敏 JSON 对象中的字段, 返回是否有字段被脱敏
+func (r *Recorder) redactJSON(v interface{}) bool {
+	redacted := false
+	switch d := v.(type) {
+	case map[string]interface{}:
+		for k, sub := range d {
+			if r.fields[strings.ToLower(k)] {
+				d[k] = Redacted
+				redacted = true
+			} else if r.redactJSON(sub) {
+				redacted = true
+			}
+		}
+	case []interface{}:
+		for _, sub := range d {
+			if r.redactJSON(sub) {
+				redacted = true
+			}
+		}
+	}
+	return redacted
+}
+
+func encodeBody(body []byte) (string, bool) {
+	if utf8.Valid(body) {
+		return string(body), false
+	}
+	return base64.StdEncoding.EncodeToString(body), true
+}
+
+func decodeBody(body string, isBase64 bool) ([]byte, error) {
+	if !isBase64 {
+		return []byte(body), nil
+	}
+	return base64.StdEncoding.DecodeString(body)
+}
@@ -0,0 +1,166 @@
+package httpmock
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	hits := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		b, _ := ioutil.ReadAll(r.Body)
+		switch r.URL.Path {
+		case "/token":
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Set-Cookie", "session=secret-cookie")
+			w.Write([]byte(`{"access_token":"secret-token","expires_in":3600}`))
+		case "/binary":
+			w.Write([]byte{0xff, 0x00, 0xfe})
+		default:
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte(r.Method + " " + r.URL.Path + " " + string(b)))
+		}
+	}))
+	defer srv.Close()
+
+	cassette := filepath.Join(t.TempDir(), "fixtures", "partner.json")
+	rec, err := NewRecorder(RecorderConfig{Cassette: cassette, Mode: ModeRecord})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hc := &http.Client{Transport: rec}
+
+	form := url.Values{"grant_type": {"client_credentials"}, "client_secret": {"secret-client"}}
+	resp, body := do(t, hc, http.MethodPost, srv.URL+"/token?api_key=secret-key", form.Encode(),
+		http.Header{"Authorization": {"Basic secret-basic"}, "Content-Type": {"application/x-www-form-urlencoded"}})
+	if !strings.Contains(body, "secret-token") || resp.Header.Get("Set-Cookie") == "" {
+		t.Fatalf("record mode should return the real response: %q", body)
+	}
+	do(t, hc, http.MethodPut, srv.URL+"/items/1", "a", nil)
+	do(t, hc, http.MethodPut, srv.URL+"/items/1", "b", nil)
+	do(t, hc, http.MethodGet, srv.URL+"/binary", "", nil)
+
+	b, err := ioutil.ReadFile(cassette)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"secret-token", "secret-cookie", "secret-basic", "secret-client", "secret-key"} {
+		if strings.Contains(string(b), secret) {
+			t.Fatalf("cassette contains %q:\n%s", secret, b)
+		}
+	}
+
+	// 回放不发送真实请求
+	hits = 0
+	rec, err = NewRecorder(RecorderConfig{Cassette: cassette, Match: MatchAll(MatchMethod, MatchURL, MatchBody)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hc = &http.Client{Transport: rec}
+
+	resp, body = do(t, hc, http.MethodPost, srv.URL+"/token?api_key=other-key", form.Encode(),
+		http.Header{"Content-Type": {"application/x-www-form-urlencoded"}})
+	if resp.StatusCode != http.StatusOK || body != `{"access_token":"REDACTED","expires_in":3600}` {
+		t.Fatalf("replay token %d %q", resp.StatusCode, body)
+	}
+	if _, body = do(t, hc, http.MethodPut, srv.URL+"/items/1", "b", nil); body != "PUT /items/1 b" {
+		t.Fatalf("MatchBody should select the second interaction, got %q", body)
+	}
+	if _, body = do(t, hc, http.MethodGet, srv.URL+"/binary", "", nil); body != "\xff\x00\xfe" {
+		t.Fatalf("binary body %q", body)
+	}
+	if _, err = hc.Get(srv.URL + "/missing"); !errors.Is(err, ErrInteractionNotFound) {
+		t.Fatalf("expect ErrInteractionNotFound, got %v", err)
+	}
+	if hits != 0 {
+		t.Fatalf("replay mode sent %d real requests", hits)
+	}
+
+	// ModeReplayOrRecord 追加新的记录
+	rec, err = NewRecorder(RecorderConfig{Cassette: cassette, Mode: ModeReplayOrRecord})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hc = &http.Client{Transport: rec}
+	do(t, hc, http.MethodGet, srv.URL+"/binary", "", nil)
+	do(t, hc, http.MethodGet, srv.URL+"/new", "", nil)
+	if hits != 1 || len(rec.Interactions()) != 5 {
+		t.Fatalf("hits %d interactions %d", hits, len(rec.Interactions()))
+	}
+
+	if _, err = NewRecorder(RecorderConfig{Cassette: filepath.Join(t.TempDir(), "none.json")}); err == nil {
+		t.Fatal("replay mode should fail without cassette")
+	}
+}
+
+func TestRecorderCustomRedact(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	redact := func(i *Interaction) {
+		u, _ := url.Parse(i.Request.URL)
+		q := u.Query()
+		q.Set("session", Redacted)
+		u.RawQuery = q.Encode()
+		i.Request.URL = u.String()
+	}
+	cassette := filepath.Join(t.TempDir(), "custom.json")
+	rec, err := NewRecorder(RecorderConfig{Cassette: cassette, Mode: ModeRecord, Redact: redact})
+	if err != nil {
+		t.Fatal(err)
+	}
+	do(t, &http.Client{Transport: rec}, http.MethodGet, srv.URL+"/items?session=secret-1", "", nil)
+
+	// 回放时请求同样经过自定义脱敏后匹配
+	rec, err = NewRecorder(RecorderConfig{Cassette: cassette, Redact: redact})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, body := do(t, &http.Client{Transport: rec}, http.MethodGet, srv.URL+"/items?session=secret-2", "", nil); body != "ok" {
+		t.Fatalf("replay with custom redact %q", body)
+	}
+}
+
+func TestRecorderPreserveJSON(t *testing.T) {
+	const raw = `{"id":12345678901234567890,"html":"<b>","b":1,"a":2}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/token" {
+			w.Write([]byte(`{"id":12345678901234567890,"token":"secret"}`))
+			return
+		}
+		w.Write([]byte(raw))
+	}))
+	defer srv.Close()
+
+	cassette := filepath.Join(t.TempDir(), "json.json")
+	rec, err := NewRecorder(RecorderConfig{Cassette: cassette, Mode: ModeRecord})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hc := &http.Client{Transport: rec}
+	do(t, hc, http.MethodGet, srv.URL+"/item", "", nil)
+	do(t, hc, http.MethodGet, srv.URL+"/token", "", nil)
+
+	// 未脱敏的 body 原样回放, 脱敏的 body 保留数字精度
+	rec, err = NewRecorder(RecorderConfig{Cassette: cassette})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hc = &http.Client{Transport: rec}
+	if _, body := do(t, hc, http.MethodGet, srv.URL+"/item", "", nil); body != raw {
+		t.Fatalf("replay body %q, want %q", body, raw)
+	}
+	if _, body := do(t, hc, http.MethodGet, srv.URL+"/token", "", nil); body != `{"id":12345678901234567890,"token":"REDACTED"}` {
+		t.Fatalf("replay redacted body %q", body)
+	}
+}
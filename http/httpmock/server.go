@@ -0,0 +1,59 @@
+package httpmock
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+// Server 基于 httptest.Server 的 mock 服务端
+// 没有匹配期望的请求响应 501 Not Implemented
+type Server struct {
+	*httptest.Server
+	*mock
+}
+
+// NewServer 启动 mock 服务端, 测试结束时关闭服务端并检查未满足的期望
+func NewServer(t TestingT) *Server {
+	s := &Server{mock: newMock(t)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	t.Cleanup(func() {
+		s.Close()
+		s.AssertExpectations()
+	})
+	return s
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	e := s.handle(r)
+	if e == nil {
+		http.Error(w, "httpmock: no expectation for "+r.Method+" "+r.URL.Path, http.StatusNotImplemented)
+		return
+	}
+
+	if e.delay > 0 {
+		timer := time.NewTimer(e.delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if e.err != nil {
+		if hj, ok := w.(http.Hijacker); ok {
+			if conn, _, err := hj.Hijack(); err == nil {
+				conn.Close()
+				return
+			}
+		}
+		panic(http.ErrAbortHandler)
+	}
+
+	for k, vs := range e.respHeader {
+		w.Header()[k] = append([]string(nil), vs...)
+	}
+	w.WriteHeader(e.status)
+	w.Write(e.respBody)
+}
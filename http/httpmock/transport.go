@@ -0,0 +1,64 @@
+package httpmock
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Transport 不监听端口的 mock http.RoundTripper, 期望只匹配 method 与 path, 不区分 host
+// 替换 client 的 Transport 即可使用, 如 c.HTTPClient.Transport = httpmock.NewTransport(t)
+// 没有匹配期望的请求返回满足 errors.Is(err, ErrUnexpectedRequest) 的 error
+type Transport struct {
+	*mock
+}
+
+// NewTransport 创建 mock Transport, 测试结束时检查未满足的期望
+func NewTransport(t TestingT) *Transport {
+	tr := &Transport{mock: newMock(t)}
+	t.Cleanup(func() {
+		tr.AssertExpectations()
+	})
+	return tr
+}
+
+// RoundTrip ...
+func (tr *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	e := tr.handle(req)
+	if e == nil {
+		return nil, fmt.Errorf("%w %s %s", ErrUnexpectedRequest, req.Method, req.URL)
+	}
+
+	if e.delay > 0 {
+		timer := time.NewTimer(e.delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+
+	header := e.respHeader.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	header.Set("Content-Length", strconv.Itoa(len(e.respBody)))
+	return &http.Response{
+		Status:        strconv.Itoa(e.status) + " " + http.StatusText(e.status),
+		StatusCode:    e.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(e.respBody)),
+		ContentLength: int64(len(e.respBody)),
+		Request:       req,
+	}, nil
+}
@@ -1,9 +1,21 @@
 package http
 
-import "io"
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/url"
+	"strings"
+	"sync"
+)
 
 // MarshalHandler defines a conversion between byte sequence and data interface.
-// 暂不支持， 后续考虑通过content type 实现多种marshal方式
+// 通过 Content-Type 选择对应的 MarshalHandler, 见 RegisterMarshalHandler
 type MarshalHandler interface {
 	// Marshal marshals "v" into byte sequence.
 	Marshal(v interface{}) ([]byte, error)
@@ -25,3 +37,240 @@ type Decoder interface {
 type Encoder interface {
 	Encode(v interface{}) error
 }
+
+var (
+	marshalersLock sync.RWMutex
+	marshalers     = map[string]MarshalHandler{
+		ApplicationJSON:       JSONMarshaler{},
+		ApplicationXML:        XMLMarshaler{},
+		TextXML:               XMLMarshaler{},
+		ApplicationUrlencoded: FormMarshaler{},
+		TextPlain:             TextMarshaler{},
+	}
+)
+
+// RegisterMarshalHandler 注册 Content-Type 对应的 MarshalHandler, 已存在则覆盖
+// contentType 中的参数部分(如 charset)会被忽略
+func RegisterMarshalHandler(contentType string, handler MarshalHandler) {
+	marshalersLock.Lock()
+	defer marshalersLock.Unlock()
+
+	marshalers[mediaType(contentType)] = handler
+}
+
+// GetMarshalHandler 获取 Content-Type 对应的 MarshalHandler
+// 未注册的 "+json"、"+xml" 后缀类型(如 application/problem+json)将使用 JSON、XML 处理
+func GetMarshalHandler(contentType string) (MarshalHandler, bool) {
+	return lookupMarshalHandler(nil, contentType)
+}
+
+// lookupMarshalHandler 优先从 local 中查找, 再查找全局注册的 MarshalHandler
+func lookupMarshalHandler(local map[string]MarshalHandler, contentType string) (MarshalHandler, bool) {
+	mt := mediaType(contentType)
+	if h, ok := local[mt]; ok {
+		return h, true
+	}
+
+	marshalersLock.RLock()
+	defer marshalersLock.RUnlock()
+
+	if h, ok := marshalers[mt]; ok {
+		return h, true
+	}
+
+	switch {
+	case strings.HasSuffix(mt, "+json"):
+		h, ok := marshalers[ApplicationJSON]
+		return h, ok
+	case strings.HasSuffix(mt, "+xml"):
+		h, ok := marshalers[ApplicationXML]
+		return h, ok
+	}
+
+	return nil, false
+}
+
+// mediaType 去除 Content-Type 中的参数并转为小写
+func mediaType(contentType string) string {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mt = strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0])
+	}
+	return strings.ToLower(mt)
+}
+
+// JSONMarshaler application/json
+type JSONMarshaler struct{}
+
+// Marshal ...
+func (JSONMarshaler) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal ...
+func (JSONMarshaler) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// NewDecoder ...
+func (JSONMarshaler) NewDecoder(r io.Reader) Decoder {
+	return json.NewDecoder(r)
+}
+
+// NewEncoder ...
+func (JSONMarshaler) NewEncoder(w io.Writer) Encoder {
+	return json.NewEncoder(w)
+}
+
+// XMLMarshaler application/xml, text/xml
+type XMLMarshaler struct{}
+
+// Marshal ...
+func (XMLMarshaler) Marshal(v interface{}) ([]byte, error) {
+	return xml.Marshal(v)
+}
+
+// Unmarshal ...
+func (XMLMarshaler) Unmarshal(data []byte, v interface{}) error {
+	return xml.Unmarshal(data, v)
+}
+
+// NewDecoder ...
+func (XMLMarshaler) NewDecoder(r io.Reader) Decoder {
+	return xml.NewDecoder(r)
+}
+
+// NewEncoder ...
+func (XMLMarshaler) NewEncoder(w io.Writer) Encoder {
+	return xml.NewEncoder(w)
+}
+
+// FormMarshaler application/x-www-form-urlencoded
+// Marshal 支持的类型与编码规则同 EncodeValues, Unmarshal 支持 url.Values、map[string]string、map[string][]string
+type FormMarshaler struct{}
+
+// Marshal ...
+func (FormMarshaler) Marshal(v interface{}) ([]byte, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	values, err := EncodeValues(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(values.Encode()), nil
+}
+
+// Unmarshal ...
+func (FormMarshaler) Unmarshal(data []byte, v interface{}) error {
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		return err
+	}
+
+	switch d := v.(type) {
+	case *url.Values:
+		*d = values
+	case *map[string][]string:
+		*d = values
+	case *map[string]string:
+		m := make(map[string]string, len(values))
+		for k := range values {
+			m[k] = values.Get(k)
+		}
+		*d = m
+	default:
+		return fmt.Errorf("form unmarshal unsupported type %T", v)
+	}
+
+	return nil
+}
+
+// NewDecoder ...
+func (m FormMarshaler) NewDecoder(r io.Reader) Decoder {
+	return &readAllDecoder{r: r, unmarshal: m.Unmarshal}
+}
+
+// NewEncoder ...
+func (m FormMarshaler) NewEncoder(w io.Writer) Encoder {
+	return &writeEncoder{w: w, marshal: m.Marshal}
+}
+
+// TextMarshaler text/plain
+// 支持 string、[]byte 以及实现了 encoding.TextMarshaler/encoding.TextUnmarshaler 的类型
+type TextMarshaler struct{}
+
+// Marshal ...
+func (TextMarshaler) Marshal(v interface{}) ([]byte, error) {
+	switch d := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []byte(d), nil
+	case []byte:
+		return d, nil
+	case encoding.TextMarshaler:
+		return d.MarshalText()
+	case fmt.Stringer:
+		return []byte(d.String()), nil
+	}
+
+	return nil, fmt.Errorf("text marshal unsupported type %T", v)
+}
+
+// Unmarshal ...
+func (TextMarshaler) Unmarshal(data []byte, v interface{}) error {
+	switch d := v.(type) {
+	case *string:
+		*d = string(data)
+	case *[]byte:
+		*d = append((*d)[:0], data...)
+	case encoding.TextUnmarshaler:
+		return d.UnmarshalText(data)
+	default:
+		return fmt.Errorf("text unmarshal unsupported type %T", v)
+	}
+
+	return nil
+}
+
+// NewDecoder ...
+func (m TextMarshaler) NewDecoder(r io.Reader) Decoder {
+	return &readAllDecoder{r: r, unmarshal: m.Unmarshal}
+}
+
+// NewEncoder ...
+func (m TextMarshaler) NewEncoder(w io.Writer) Encoder {
+	return &writeEncoder{w: w, marshal: m.Marshal}
+}
+
+// readAllDecoder 读取全部数据后再 Unmarshal, 用于不支持流式解析的格式
+type readAllDecoder struct {
+	r         io.Reader
+	unmarshal func(data []byte, v interface{}) error
+}
+
+func (d *readAllDecoder) Decode(v interface{}) error {
+	data, err := ioutil.ReadAll(d.r)
+	if err != nil {
+		return err
+	}
+	return d.unmarshal(data, v)
+}
+
+// writeEncoder Marshal 后整体写入, 用于不支持流式编码的格式
+type writeEncoder struct {
+	w       io.Writer
+	marshal func(v interface{}) ([]byte, error)
+}
+
+func (e *writeEncoder) Encode(v interface{}) error {
+	data, err := e.marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(e.w, bytes.NewReader(data))
+	return err
+}
@@ -0,0 +1,171 @@
+package http
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MetricLabels 指标标签
+// Route 为请求的路径模板(见 WithPathParam)或 WithRoute 配置的路由名, 都未配置时为空, 避免以原始 url 作为标签导致基数过大
+type MetricLabels struct {
+	Host   string
+	Method string
+	Route  string
+}
+
+// RequestMetric 一次实际发送的请求(重试与重定向分别统计)的指标
+type RequestMetric struct {
+	MetricLabels
+	StatusCode    int           // 发送失败时为 0
+	Duration      time.Duration // 从发送请求到响应 body 读取完毕或关闭
+	BytesSent     int64         // 请求 body 字节数
+	BytesReceived int64         // 响应 body 字节数
+}
+
+// StatusClass 状态码分类, 如 "2xx", 发送失败时为 "error"
+func (m RequestMetric) StatusClass() string {
+	if m.StatusCode < 100 || m.StatusCode > 599 {
+		return "error"
+	}
+	return strconv.Itoa(m.StatusCode/100) + "xx"
+}
+
+// MetricsSink 请求指标的接收方, 实现需要并发安全
+// 内置 ExpvarMetrics 与 PrometheusMetrics 两种实现
+type MetricsSink interface {
+	// InFlight 进行中的请求数变化, delta 为 1 或 -1
+	InFlight(labels MetricLabels, delta int)
+	// ObserveRequest 请求结束
+	ObserveRequest(m RequestMetric)
+	// ObserveRetry 请求失败后重试
+	ObserveRetry(labels MetricLabels)
+}
+
+// WithMetrics 采集 client 实际发送的请求(不包括命中缓存与被熔断、限流拒绝的请求)的指标
+func WithMetrics(sink MetricsSink) Options {
+	return func(o *options) {
+		o.metrics = sink
+	}
+}
+
+// WithRoute 配置请求的路由名, 作为指标的 Route 标签, 优先于路径模板
+func WithRoute(route string) RequestOptions {
+	return func(o *requestOptions) {
+		o.route = route
+	}
+}
+
+type routeKey struct{}
+
+// withRoute 将指标使用的路由写入 context, 未配置 WithRoute 时使用路径模板
+func withRoute(ctx context.Context, opt *requestOptions) context.Context {
+	route := opt.route
+	if route == "" && opt.pathParams != nil {
+		route = routeTemplate(opt.url)
+	}
+	// ctx 为 nil 时由 http.NewRequestWithContext 返回错误
+	if route == "" || ctx == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, routeKey{}, route)
+}
+
+// routeTemplate 获取 url 模板中的路径部分
+func routeTemplate(rawURL string) string {
+	if i := strings.IndexAny(rawURL, "?#"); i >= 0 {
+		rawURL = rawURL[:i]
+	}
+	if i := strings.Index(rawURL, "://"); i >= 0 {
+		rawURL = rawURL[i+3:]
+		if j := strings.IndexByte(rawURL, '/'); j >= 0 {
+			return rawURL[j:]
+		}
+		return "/"
+	}
+	return rawURL
+}
+
+func metricLabels(req *http.Request) MetricLabels {
+	route, _ := req.Context().Value(routeKey{}).(string)
+	return MetricLabels{Host: req.URL.Host, Method: req.Method, Route: route}
+}
+
+// metricsMiddleware 统计每次实际发送的请求
+func metricsMiddleware(sink MetricsSink) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			labels := metricLabels(req)
+			sink.InFlight(labels, 1)
+
+			r := &requestRecord{sink: sink, metric: RequestMetric{MetricLabels: labels}, start: time.Now()}
+			if req.Body != nil && req.Body != http.NoBody {
+				req = req.Clone(req.Context())
+				req.Body = &countingBody{ReadCloser: req.Body, n: &r.sent}
+			}
+
+			resp, err := next.RoundTrip(req)
+			if err != nil {
+				r.finish()
+				return resp, err
+			}
+			r.metric.StatusCode = resp.StatusCode
+			if resp.Body == nil || resp.Body == http.NoBody {
+				r.finish()
+				return resp, nil
+			}
+			resp.Body = &countingBody{ReadCloser: resp.Body, n: &r.received, done: r.finish}
+			return resp, nil
+		})
+	}
+}
+
+// requestRecord 一次请求的指标, 请求失败或响应 body 读取完毕、关闭时上报
+type requestRecord struct {
+	sink   MetricsSink
+	metric RequestMetric
+	start  time.Time
+
+	sent     int64
+	received int64
+	once     sync.Once
+}
+
+func (r *requestRecord) finish() {
+	r.once.Do(func() {
+		r.metric.Duration = time.Since(r.start)
+		r.metric.BytesSent = atomic.LoadInt64(&r.sent)
+		r.metric.BytesReceived = atomic.LoadInt64(&r.received)
+		r.sink.InFlight(r.metric.MetricLabels, -1)
+		r.sink.ObserveRequest(r.metric)
+	})
+}
+
+// countingBody 统计读取的字节数, 读取到 EOF 或关闭时调用 done
+type countingBody struct {
+	io.ReadCloser
+	n    *int64
+	done func()
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	atomic.AddInt64(b.n, int64(n))
+	if err == io.EOF && b.done != nil {
+		b.done()
+	}
+	return n, err
+}
+
+func (b *countingBody) Close() error {
+	err := b.ReadCloser.Close()
+	if b.done != nil {
+		b.done()
+	}
+	return err
+}
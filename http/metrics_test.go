@@ -0,0 +1,134 @@
+package http
+
+import (
+	"context"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// multiSink 将指标同时写入多个 MetricsSink
+type multiSink []MetricsSink
+
+func (s multiSink) InFlight(labels MetricLabels, delta int) {
+	for _, sink := range s {
+		sink.InFlight(labels, delta)
+	}
+}
+
+func (s multiSink) ObserveRequest(m RequestMetric) {
+	for _, sink := range s {
+		sink.ObserveRequest(m)
+	}
+}
+
+func (s multiSink) ObserveRetry(labels MetricLabels) {
+	for _, sink := range s {
+		sink.ObserveRetry(labels)
+	}
+}
+
+func TestClientMetrics(t *testing.T) {
+	var attempts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/flaky" && atomic.AddInt32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", TextPlain)
+		w.Write([]byte("hello"))
+	}))
+	defer srv.Close()
+
+	prom := NewPrometheusMetrics("test", 0.5, 1)
+	ev := NewExpvarMetrics("test_http_client_metrics")
+	policy := DefaultRetryPolicy()
+	policy.BaseDelay = time.Millisecond
+	c := NewClient(WithBaseURL(srv.URL), WithMetrics(multiSink{prom, ev}), WithRetry(policy))
+
+	for _, id := range []string{"1", "2"} {
+		req, _ := c.NewRequest(http.MethodPost, WithURL("/users/{id}"), WithPathParam("id", id), WithBodyString("abc"))
+		if _, err := c.Do(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req, _ := c.NewRequest(http.MethodGet, WithURL("/flaky"), WithRoute("flaky"))
+	if _, err := c.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	req, _ = c.NewRequest(http.MethodGet, WithURL("http://127.0.0.1:1/down"))
+	c.Do(req, WithRequestRetry(RetryPolicy{}))
+
+	host := strings.TrimPrefix(srv.URL, "http://")
+	text := string(prom.Bytes())
+	for _, want := range []string{
+		`test_http_client_requests_total{host="` + host + `",method="POST",route="/users/{id}",status_class="2xx"} 2`,
+		`test_http_client_requests_total{host="` + host + `",method="GET",route="flaky",status_class="5xx"} 1`,
+		`test_http_client_requests_total{host="` + host + `",method="GET",route="flaky",status_class="2xx"} 1`,
+		`test_http_client_requests_total{host="127.0.0.1:1",method="GET",route="",status_class="error"} 1`,
+		`test_http_client_request_duration_seconds_bucket{host="` + host + `",method="POST",route="/users/{id}",le="+Inf"} 2`,
+		`test_http_client_request_duration_seconds_count{host="` + host + `",method="GET",route="flaky"} 2`,
+		`test_http_client_retries_total{host="` + host + `",method="GET",route="flaky"} 1`,
+		`test_http_client_in_flight_requests{host="` + host + `",method="POST",route="/users/{id}"} 0`,
+		`test_http_client_request_bytes_total{host="` + host + `",method="POST",route="/users/{id}"} 6`,
+		`test_http_client_response_bytes_total{host="` + host + `",method="POST",route="/users/{id}"} 10`,
+		"# TYPE test_http_client_request_duration_seconds histogram",
+	} {
+		if !strings.Contains(text, want+"\n") {
+			t.Fatalf("missing %q in:\n%s", want, text)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	prom.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain; version=0.0.4") || rec.Body.Len() != len(text) {
+		t.Fatalf("ServeHTTP %q", rec.Header().Get("Content-Type"))
+	}
+
+	key := "POST " + host + " /users/{id}"
+	if v := ev.Map().Get("requests").(*expvar.Map).Get(key + " 2xx"); v == nil || v.String() != "2" {
+		t.Fatalf("expvar requests %v", v)
+	}
+	if v := ev.Map().Get("duration_seconds").(*expvar.Map).Get(key + " 2xx"); v == nil {
+		t.Fatal("expvar duration should use the same key as requests")
+	}
+	if v := ev.Map().Get("duration_bucket").(*expvar.Map).Get(key + " 2xx 10"); v == nil || v.String() != "2" {
+		t.Fatalf("expvar duration bucket %v", v)
+	}
+	if v := ev.Map().Get("retries").(*expvar.Map).Get("GET " + host + " flaky"); v == nil || v.String() != "1" {
+		t.Fatalf("expvar retries %v", v)
+	}
+	if v := ev.Map().Get("in_flight").(*expvar.Map).Get(key); v == nil || v.String() != "0" {
+		t.Fatalf("expvar in flight %v", v)
+	}
+	if v := expvar.Get("test_http_client_metrics"); v == nil {
+		t.Fatal("expvar not published")
+	}
+}
+
+func TestRouteTemplate(t *testing.T) {
+	tests := map[string]string{
+		"/users/{id}?a=1":                "/users/{id}",
+		"https://api.example.com/v1/{x}": "/v1/{x}",
+		"https://api.example.com":        "/",
+		"users/{id}#top":                 "users/{id}",
+	}
+	for in, want := range tests {
+		if got := routeTemplate(in); got != want {
+			t.Fatalf("routeTemplate(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if got := promLabels(MetricLabels{Route: "a\"b\\c\n"}); got != `host="",method="",route="a\"b\\c\n"` {
+		t.Fatalf("escaped labels %s", got)
+	}
+
+	// nil context 与未配置路由时一样返回错误
+	var ctx context.Context
+	if _, err := NewClient().NewRequest(http.MethodGet, WithURL("http://example.test/{id}"), WithContext(ctx), WithPathParam("id", "1")); err == nil {
+		t.Fatal("nil context should return error")
+	}
+}
@@ -0,0 +1,62 @@
+package http
+
+import (
+	"net/http"
+)
+
+// RoundTripperFunc 函数形式的 http.RoundTripper
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+// RoundTrip ...
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Middleware 请求中间件, 包装下一层 RoundTripper, 可用于鉴权、日志、监控、请求头注入、故障注入等
+// 中间件运行在每一次实际发送的请求上(包括重试与重定向)
+// 与 http.RoundTripper 的约定一致, 修改请求前需要先 req.Clone
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// chainMiddleware 组装中间件, 先配置的中间件位于外层, 最先处理请求
+func chainMiddleware(rt http.RoundTripper, middlewares ...[]Middleware) http.RoundTripper {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		for j := len(middlewares[i]) - 1; j >= 0; j-- {
+			rt = middlewares[i][j](rt)
+		}
+	}
+	return rt
+}
+
+// transport 获取本次请求使用的 RoundTripper
+// client 中间件位于外层, 请求中间件次之, 然后依次是响应缓存、鉴权与签名, 熔断、限流等内置中间件位于最内层
+func (c *Client) transport(opt *doOptions) http.RoundTripper {
+	rt := c.HTTPClient.Transport
+
+	// 请求级配置覆盖 client 配置
+	var pipeline []Middleware
+	// 单次请求的鉴权与签名可能对应不同的身份, 缓存的 key 不包含凭证, 不使用缓存
+	// 流式处理的响应可能很大, 不读入内存缓存
+	streamed := opt.stream != nil || opt.resumeFile != ""
+	if c.opt.cache != nil && (opt.cache == nil || *opt.cache) && opt.auth == nil && opt.signer == nil && !streamed {
+		pipeline = append(pipeline, (&httpCache{store: c.opt.cache, maxEntrySize: c.opt.cacheMaxEntrySize}).middleware)
+	}
+	if opt.auth != nil {
+		pipeline = append(pipeline, opt.auth.Wrap)
+	} else if c.opt.auth != nil {
+		pipeline = append(pipeline, c.opt.auth.Wrap)
+	}
+	if opt.signer != nil {
+		pipeline = append(pipeline, signMiddleware(opt.signer))
+	} else if c.opt.signer != nil {
+		pipeline = append(pipeline, signMiddleware(c.opt.signer))
+	}
+
+	if len(c.opt.middlewares) == 0 && len(opt.middlewares) == 0 && len(pipeline) == 0 && len(c.middlewares) == 0 {
+		return rt
+	}
+
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return chainMiddleware(rt, c.opt.middlewares, opt.middlewares, pipeline, c.middlewares)
+}
@@ -0,0 +1,199 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// multipartPart multipart/form-data 中的一个字段或文件
+type multipartPart struct {
+	field       string
+	filename    string // 为空表示普通字段
+	contentType string
+
+	open       func() (io.ReadCloser, error)
+	replayable bool // open 是否可以重复调用
+}
+
+// WithMultipartField 添加 multipart/form-data 普通字段
+func WithMultipartField(field string, value string) RequestOptions {
+	return withMultipartPart(multipartPart{
+		field: field,
+		open: func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(strings.NewReader(value)), nil
+		},
+		replayable: true,
+	})
+}
+
+// WithMultipartFile 添加 multipart/form-data 文件, 文件内容在发送时才读取
+// 文件名取 path 的最后一段, Content-Type 依据扩展名推断, 无法推断时为 application/octet-stream
+func WithMultipartFile(field string, path string) RequestOptions {
+	contentType := mime.TypeByExtension(filepath.Ext(path))
+	if contentType == "" {
+		contentType = ApplicationOctetStream
+	}
+
+	return withMultipartPart(multipartPart{
+		field:       field,
+		filename:    filepath.Base(path),
+		contentType: contentType,
+		open: func() (io.ReadCloser, error) {
+			return os.Open(path)
+		},
+		replayable: true,
+	})
+}
+
+// WithMultipartReader 添加 multipart/form-data 文件, 内容从 r 中流式读取
+// r 只能读取一次, 因此包含该字段的请求无法重试; r 若实现了 io.Closer 将在读取完成后关闭
+func WithMultipartReader(field string, filename string, contentType string, r io.Reader) RequestOptions {
+	return withMultipartPart(multipartPart{
+		field:       field,
+		filename:    filename,
+		contentType: contentType,
+		open: func() (io.ReadCloser, error) {
+			if rc, ok := r.(io.ReadCloser); ok {
+				return rc, nil
+			}
+			return ioutil.NopCloser(r), nil
+		},
+	})
+}
+
+// WithMultipartBytes 添加 multipart/form-data 文件, 内容为 data
+func WithMultipartBytes(field string, filename string, contentType string, data []byte) RequestOptions {
+	return withMultipartPart(multipartPart{
+		field:       field,
+		filename:    filename,
+		contentType: contentType,
+		open: func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(data)), nil
+		},
+		replayable: true,
+	})
+}
+
+// WithUploadProgress 配置 multipart/form-data 上传进度回调, written 为已发送的字节数
+func WithUploadProgress(fn func(written int64)) RequestOptions {
+	return func(o *requestOptions) {
+		o.uploadProgress = fn
+	}
+}
+
+func withMultipartPart(part multipartPart) RequestOptions {
+	return func(o *requestOptions) {
+		o.multipart = append(o.multipart[:len(o.multipart):len(o.multipart)], part)
+	}
+}
+
+// multipartBody 构造 multipart/form-data 请求 body
+// body 通过 io.Pipe 边编码边发送, 不会将文件整体读入内存
+// 所有字段均可重复读取时返回 getBody, 用于重试与重定向
+func multipartBody(parts []multipartPart, progress func(int64)) (body io.ReadCloser, contentType string, getBody func() (io.ReadCloser, error)) {
+	boundary := multipart.NewWriter(ioutil.Discard).Boundary()
+	contentType = mime.FormatMediaType(MultipartFormdata, map[string]string{"boundary": boundary})
+
+	newBody := func() (io.ReadCloser, error) {
+		return &multipartReader{parts: parts, boundary: boundary, progress: progress}, nil
+	}
+
+	replayable := true
+	for _, p := range parts {
+		replayable = replayable && p.replayable
+	}
+	if replayable {
+		getBody = newBody
+	}
+
+	body, _ = newBody()
+	return body, contentType, getBody
+}
+
+// multipartReader 首次 Read 时才启动编码协程, 避免请求未发送时协程泄漏
+type multipartReader struct {
+	parts    []multipartPart
+	boundary string
+	progress func(int64)
+
+	once    sync.Once
+	pr      *io.PipeReader
+	written int64
+}
+
+func (r *multipartReader) start() {
+	r.once.Do(func() {
+		pr, pw := io.Pipe()
+		r.pr = pr
+
+		go func() {
+			mw := multipart.NewWriter(pw)
+			err := mw.SetBoundary(r.boundary)
+			if err == nil {
+				err = writeMultipartParts(mw, r.parts)
+			}
+			if err == nil {
+				err = mw.Close()
+			}
+			pw.CloseWithError(err)
+		}()
+	})
+}
+
+func (r *multipartReader) Read(p []byte) (int, error) {
+	r.start()
+
+	n, err := r.pr.Read(p)
+	if n > 0 && r.progress != nil {
+		r.progress(atomic.AddInt64(&r.written, int64(n)))
+	}
+	return n, err
+}
+
+func (r *multipartReader) Close() error {
+	r.start()
+	return r.pr.Close()
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func writeMultipartParts(mw *multipart.Writer, parts []multipartPart) error {
+	for _, p := range parts {
+		h := make(textproto.MIMEHeader)
+		if p.filename == "" {
+			h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"`, quoteEscaper.Replace(p.field)))
+		} else {
+			h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+				quoteEscaper.Replace(p.field), quoteEscaper.Replace(p.filename)))
+		}
+		if p.contentType != "" {
+			h.Set("Content-Type", p.contentType)
+		}
+
+		w, err := mw.CreatePart(h)
+		if err != nil {
+			return err
+		}
+
+		rc, err := p.open()
+		if err != nil {
+			return fmt.Errorf("open multipart field %s err %w", p.field, err)
+		}
+		_, err = io.Copy(w, rc)
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("write multipart field %s err %w", p.field, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrTokenFetch 获取 OAuth2 token 失败
+var ErrTokenFetch = errors.New("fetch oauth2 token failed")
+
+// Token OAuth2 access token
+type Token struct {
+	AccessToken string
+	TokenType   string    // 默认为 Bearer
+	Expiry      time.Time // 零值表示不过期
+}
+
+// ClientCredentialsConfig OAuth2 client credentials 模式配置
+type ClientCredentialsConfig struct {
+	TokenURL       string
+	ClientID       string
+	ClientSecret   string
+	Scopes         []string
+	EndpointParams url.Values // 请求 token 时附加的参数, 如 audience
+
+	AuthInParams bool          // 为 true 时 client_id、client_secret 放在请求参数中, 默认使用 Basic 鉴权
+	ExpiryDelta  time.Duration // token 在过期前多久刷新, 默认 10s
+	HTTPClient   *http.Client  // 请求 token 使用的 client, 默认 http.DefaultClient
+}
+
+// ClientCredentials OAuth2 client credentials 模式的 token 来源, 同时实现 AuthProvider
+// token 缓存到过期前 ExpiryDelta, 收到 401 响应时刷新 token 并重新发送一次
+type ClientCredentials struct {
+	cfg ClientCredentialsConfig
+
+	lock  sync.Mutex
+	token *Token
+}
+
+// NewClientCredentials 创建 client credentials token 来源
+func NewClientCredentials(cfg ClientCredentialsConfig) *ClientCredentials {
+	if cfg.ExpiryDelta <= 0 {
+		cfg.ExpiryDelta = 10 * time.Second
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+	return &ClientCredentials{cfg: cfg}
+}
+
+// Token 获取 token, 缓存的 token 即将过期时重新获取
+// 获取失败返回的 error 满足 errors.Is(err, ErrTokenFetch)
+func (s *ClientCredentials) Token(ctx context.Context) (*Token, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.token != nil && (s.token.Expiry.IsZero() || time.Until(s.token.Expiry) > s.cfg.ExpiryDelta) {
+		return s.token, nil
+	}
+
+	token, err := s.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.token = token
+	return token, nil
+}
+
+// Invalidate 丢弃缓存的 token, 下次调用 Token 时重新获取
+func (s *ClientCredentials) Invalidate() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.token = nil
+}
+
+// invalidate 仅在缓存的仍是 token 时丢弃, 避免并发请求收到 401 时重复刷新
+func (s *ClientCredentials) invalidate(token *Token) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.token == token {
+		s.token = nil
+	}
+}
+
+// tokenResponse token 接口的响应, 见 RFC 6749 5.1 与 5.2
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func (s *ClientCredentials) fetch(ctx context.Context) (*Token, error) {
+	form := url.Values{}
+	for k, v := range s.cfg.EndpointParams {
+		form[k] = append([]string(nil), v...)
+	}
+	form.Set("grant_type", "client_credentials")
+	if len(s.cfg.Scopes) > 0 {
+		form.Set("scope", strings.Join(s.cfg.Scopes, " "))
+	}
+	if s.cfg.AuthInParams {
+		form.Set("client_id", s.cfg.ClientID)
+		form.Set("client_secret", s.cfg.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTokenFetch, err)
+	}
+	req.Header.Set("Content-Type", ApplicationUrlencoded)
+	req.Header.Set("Accept", ApplicationJSON)
+	if !s.cfg.AuthInParams {
+		req.SetBasicAuth(url.QueryEscape(s.cfg.ClientID), url.QueryEscape(s.cfg.ClientSecret))
+	}
+
+	resp, err := s.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTokenFetch, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: read body %v", ErrTokenFetch, err)
+	}
+
+	var tr tokenResponse
+	if err = json.Unmarshal(body, &tr); err != nil && resp.StatusCode < 300 {
+		return nil, fmt.Errorf("%w: decode body %v body %s", ErrTokenFetch, err, truncateBody(body))
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 || tr.AccessToken == "" {
+		if tr.Error != "" {
+			return nil, fmt.Errorf("%w: status %d %s %s", ErrTokenFetch, resp.StatusCode, tr.Error, tr.ErrorDescription)
+		}
+		return nil, fmt.Errorf("%w: status %d body %s", ErrTokenFetch, resp.StatusCode, truncateBody(body))
+	}
+
+	token := &Token{AccessToken: tr.AccessToken, TokenType: tr.TokenType}
+	if tr.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(tr.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
+
+// Wrap 实现 AuthProvider
+func (s *ClientCredentials) Wrap(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if !authAllowed(req) {
+			return next.RoundTrip(req)
+		}
+
+		token, err := s.Token(req.Context())
+		if err != nil {
+			return nil, err
+		}
+		resp, err := next.RoundTrip(withToken(req, token))
+		if err != nil || resp.StatusCode != http.StatusUnauthorized {
+			return resp, err
+		}
+
+		s.invalidate(token)
+		retry, ok := rewindRequest(req)
+		if !ok {
+			return resp, nil
+		}
+
+		refreshed, err := s.Token(req.Context())
+		if err != nil {
+			// 刷新失败时返回原始的 401 响应
+			return resp, nil
+		}
+		drainBody(resp.Body)
+		return next.RoundTrip(withToken(retry, refreshed))
+	})
+}
+
+// withToken 复制请求并设置 Authorization 请求头
+func withToken(req *http.Request, token *Token) *http.Request {
+	tokenType := token.TokenType
+	if tokenType == "" || strings.EqualFold(tokenType, "bearer") {
+		tokenType = "Bearer"
+	}
+
+	r := req.Clone(req.Context())
+	r.Header.Set("Authorization", tokenType+" "+token.AccessToken)
+	return r
+}
@@ -1,20 +1,20 @@
 package http
 
 import (
+	"context"
 	"io"
 	"net/http"
 	"net/url"
 	"time"
-
-	"github.com/hashicorp/go.net/proxy"
 )
 
 var (
 	defaultOptions = options{
-		timeout: 60 * time.Second,
+		timeout:           60 * time.Second,
+		cacheMaxEntrySize: defaultCacheMaxEntrySize,
 	}
 	defaultRequestOptions = requestOptions{
-		header:      http.Header{},
+		ctx:         context.Background(),
 		contentType: ApplicationJSON,
 		url:         "",
 		body:        nil,
@@ -28,8 +28,40 @@ type Options func(o *options)
 type options struct {
 	timeout time.Duration
 
-	socks5 proxy.Dialer
-	proxy  string
+	proxy proxyOptions
+
+	marshalers map[string]MarshalHandler
+
+	retry *RetryPolicy
+
+	middlewares []Middleware
+
+	checkStatus bool
+
+	breaker *BreakerPolicy
+
+	rateLimit      *RateLimit
+	hostRateLimits map[string]RateLimit
+
+	transport transportOptions
+
+	jar http.CookieJar
+
+	auth   AuthProvider
+	signer Signer
+
+	cache             CacheStore
+	cacheMaxEntrySize int64
+
+	compressMinSize int
+	decompress      bool
+	maxResponseSize int64
+
+	baseURL string
+	header  http.Header
+	query   url.Values
+
+	metrics MetricsSink
 }
 
 func (o *options) ExecuteOptions(opt []Options) {
@@ -45,19 +77,65 @@ func WithTimeout(timeout time.Duration) Options {
 	}
 }
 
-// WithProxy 配置 http 代理地址
-//
-// Note: 若 WithProxy 与 WithSOCKS5 同时使用, WithSOCKS5 将会优先使用
-func WithProxy(proxy string) Options {
+// WithMarshalHandler 为当前 client 配置 Content-Type 对应的 MarshalHandler, 优先于 RegisterMarshalHandler 注册的
+func WithMarshalHandler(contentType string, handler MarshalHandler) Options {
+	return func(o *options) {
+		m := make(map[string]MarshalHandler, len(o.marshalers)+1)
+		for k, v := range o.marshalers {
+			m[k] = v
+		}
+		m[mediaType(contentType)] = handler
+		o.marshalers = m
+	}
+}
+
+// WithRetry 配置 client 的重试策略, 可被 WithRequestRetry 覆盖
+func WithRetry(policy RetryPolicy) Options {
 	return func(o *options) {
-		o.proxy = proxy
+		o.retry = &policy
 	}
 }
 
-// WithSOCKS5 配置 socks 代理
-func WithSOCKS5(dialer proxy.Dialer) Options {
+// WithMiddleware 追加 client 中间件, 按配置顺序执行, 先配置的位于外层
+func WithMiddleware(middlewares ...Middleware) Options {
 	return func(o *options) {
-		o.socks5 = dialer
+		o.middlewares = append(o.middlewares[:len(o.middlewares):len(o.middlewares)], middlewares...)
+	}
+}
+
+// WithStatusCheck 非 2xx 响应返回 *StatusError, 调用方无需再检查 resp.IsOK()
+func WithStatusCheck() Options {
+	return func(o *options) {
+		o.checkStatus = true
+	}
+}
+
+// WithCircuitBreaker 开启熔断, 默认按 host 区分熔断器
+// 熔断器打开时请求直接失败, 返回的 error 满足 errors.Is(err, ErrCircuitOpen)
+// ConsecutiveFailures 与 ErrorRate 均未配置时通过 Err 返回配置错误, 满足 errors.Is(err, ErrInvalidConfig)
+func WithCircuitBreaker(policy BreakerPolicy) Options {
+	return func(o *options) {
+		o.breaker = &policy
+	}
+}
+
+// WithRateLimit 开启客户端限流, limit.PerHost 为 true 时每个 host 使用独立的令牌桶
+// 未等待令牌的请求返回的 error 满足 errors.Is(err, ErrRateLimited)
+func WithRateLimit(limit RateLimit) Options {
+	return func(o *options) {
+		o.rateLimit = &limit
+	}
+}
+
+// WithHostRateLimit 为指定 host(与 req.URL.Host 一致, 包含端口)配置独立的限流, 优先于 WithRateLimit
+func WithHostRateLimit(host string, limit RateLimit) Options {
+	return func(o *options) {
+		m := make(map[string]RateLimit, len(o.hostRateLimits)+1)
+		for k, v := range o.hostRateLimits {
+			m[k] = v
+		}
+		m[host] = limit
+		o.hostRateLimits = m
 	}
 }
 
@@ -65,12 +143,21 @@ func WithSOCKS5(dialer proxy.Dialer) Options {
 type RequestOptions func(o *requestOptions)
 
 type requestOptions struct {
+	ctx context.Context
+
 	header http.Header
 
-	contentType string // 暂时不支持依据contentType做不同的解析
+	contentType string // 依据 contentType 选择 MarshalHandler 序列化 body
 	url         string
+	query       []interface{} // 追加到 url 的 query, 由 EncodeValues 编码
+	pathParams  map[string]string
+	route       string
 
-	body interface{}
+	body    interface{}
+	rawBody *rawBody
+
+	multipart      []multipartPart
+	uploadProgress func(written int64)
 }
 
 func (o *requestOptions) ExecuteOptions(opt []RequestOptions) {
@@ -79,7 +166,14 @@ func (o *requestOptions) ExecuteOptions(opt []RequestOptions) {
 	}
 }
 
-// WithURL 配置请求的完整url
+// WithContext 配置请求的 context, 用于传递调用方的取消与超时
+func WithContext(ctx context.Context) RequestOptions {
+	return func(o *requestOptions) {
+		o.ctx = ctx
+	}
+}
+
+// WithURL 配置请求的完整url, 配置了 WithBaseURL 时可以只传路径, 路径中的 {name} 由 WithPathParam 替换
 func WithURL(url string) RequestOptions {
 	return func(o *requestOptions) {
 		o.url = url
@@ -99,24 +193,47 @@ func WithUrlBuild(scheme string, host string, path string) RequestOptions {
 	}
 }
 
-// WithContentType 设置 请求 Content-Type
+// WithQuery 追加 query 参数, 与 url 中已有的 query 合并
+func WithQuery(values url.Values) RequestOptions {
+	return withQuery(values)
+}
+
+// WithQueryMap 追加 query 参数, value 的编码规则同 EncodeValues
+func WithQueryMap(m map[string]interface{}) RequestOptions {
+	return withQuery(m)
+}
+
+// WithQueryStruct 追加 query 参数, v 为结构体或其指针, 通过 `url:"name,omitempty"` 标签配置编码方式, 详见 EncodeValues
+func WithQueryStruct(v interface{}) RequestOptions {
+	return withQuery(v)
+}
+
+func withQuery(v interface{}) RequestOptions {
+	return func(o *requestOptions) {
+		o.query = append(o.query[:len(o.query):len(o.query)], v)
+	}
+}
+
+// WithContentType 设置 请求 Content-Type, body 将使用对应的 MarshalHandler 序列化
 func WithContentType(contentType string) RequestOptions {
 	return func(o *requestOptions) {
 		o.contentType = contentType
 	}
 }
 
-// WithHeader 设置 请求头
+// WithHeader 设置 请求头, 多次配置时合并, 同名请求头以后配置的为准, 并覆盖 client 的默认请求头
+// header 会被复制, NewRequest 不会修改调用方的 header, 可在多个请求间复用
 func WithHeader(header http.Header) RequestOptions {
 	return func(o *requestOptions) {
-		o.header = header
+		o.header = mergeHeader(o.header, header)
 	}
 }
 
-// WithBody 配置请求 body
+// WithBody 配置请求 body, 使用 WithContentType 对应的 MarshalHandler 序列化, body 为 nil 时请求不带 body
 func WithBody(body interface{}) RequestOptions {
 	return func(o *requestOptions) {
 		o.body = body
+		o.rawBody = nil
 	}
 }
 
@@ -124,9 +241,27 @@ func WithBody(body interface{}) RequestOptions {
 type DoOptions func(o *doOptions)
 
 type doOptions struct {
-	responseData   interface{}
-	responseReader *io.Reader
-	response       *[]byte
+	timeout time.Duration
+	retry   *RetryPolicy
+	proxy   *string
+
+	middlewares []Middleware
+	checkStatus *bool
+	auth        AuthProvider
+	signer      Signer
+	cache       *bool
+
+	maxResponseSize *int64
+
+	responseData      interface{}
+	emptyBody         bool // 响应 body 为空时不解析 responseData, 用于 Do[T]
+	responseErrorData interface{}
+	responseReader    *io.Reader
+	response          *[]byte
+
+	stream           func(body io.ReadCloser) error
+	downloadProgress func(read int64, total int64)
+	resumeFile       string
 }
 
 func (o *doOptions) ExecuteOptions(opt []DoOptions) {
@@ -135,6 +270,34 @@ func (o *doOptions) ExecuteOptions(opt []DoOptions) {
 	}
 }
 
+// WithRequestTimeout 设置单次请求的超时时间(包含读取响应 body), 覆盖 client 的 WithTimeout
+func WithRequestTimeout(timeout time.Duration) DoOptions {
+	return func(o *doOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithRequestRetry 配置单次请求的重试策略, 覆盖 client 的 WithRetry
+func WithRequestRetry(policy RetryPolicy) DoOptions {
+	return func(o *doOptions) {
+		o.retry = &policy
+	}
+}
+
+// WithRequestMiddleware 追加单次请求的中间件, 位于 client 中间件内层, 按配置顺序执行
+func WithRequestMiddleware(middlewares ...Middleware) DoOptions {
+	return func(o *doOptions) {
+		o.middlewares = append(o.middlewares[:len(o.middlewares):len(o.middlewares)], middlewares...)
+	}
+}
+
+// WithResponseStatusCheck 配置单次请求是否将非 2xx 响应转换为 *StatusError, 覆盖 client 的 WithStatusCheck
+func WithResponseStatusCheck(check bool) DoOptions {
+	return func(o *doOptions) {
+		o.checkStatus = &check
+	}
+}
+
 // WithResponseBodyData 配置响应消息体数据
 // data 将会根据响应消息的 Content-Type 反序列化
 func WithResponseBodyData(data interface{}) DoOptions {
@@ -143,7 +306,17 @@ func WithResponseBodyData(data interface{}) DoOptions {
 	}
 }
 
+// WithResponseErrorData 配置 4xx/5xx 响应消息体数据, 与 WithResponseBodyData 类似依据 Content-Type 反序列化
+// 4xx/5xx 响应不再解析到 WithResponseBodyData 配置的数据, Do 返回 *StatusError, 其 Payload 为 data
+// body 为空或解析失败(如纯文本、HTML 错误页)时仍返回 *StatusError, Payload 为 nil, 解析错误见 StatusError.Err
+func WithResponseErrorData(data interface{}) DoOptions {
+	return func(o *doOptions) {
+		o.responseErrorData = data
+	}
+}
+
 // WithResponseBodyReader 配置响应消息体数据
+// body 已全部读入内存, 大文件下载请使用 WithResponseStream
 func WithResponseBodyReader(data *io.Reader) DoOptions {
 	return func(o *doOptions) {
 		o.responseReader = data
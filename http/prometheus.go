@@ -0,0 +1,207 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultLatencyBuckets 默认的耗时直方图分桶, 单位秒, 与 Prometheus 客户端库的默认值一致
+var DefaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// PrometheusMetrics 以 Prometheus 文本格式输出指标的 MetricsSink, 同时是 http.Handler, 可直接注册到 /metrics
+// 输出的指标:
+//
+//	http_client_requests_total{host,method,route,status_class}   请求数
+//	http_client_request_duration_seconds{host,method,route}       请求耗时直方图
+//	http_client_in_flight_requests{host,method,route}             进行中的请求数
+//	http_client_retries_total{host,method,route}                  重试次数
+//	http_client_request_bytes_total{host,method,route}            发送的请求 body 字节数
+//	http_client_response_bytes_total{host,method,route}           接收的响应 body 字节数
+type PrometheusMetrics struct {
+	namespace string
+	buckets   []float64
+
+	lock     sync.Mutex
+	requests map[statusLabels]uint64
+	series   map[MetricLabels]*promSeries
+}
+
+type statusLabels struct {
+	MetricLabels
+	class string
+}
+
+type promSeries struct {
+	inFlight      int64
+	retries       uint64
+	bytesSent     int64
+	bytesReceived int64
+
+	count   uint64
+	sum     float64
+	buckets []uint64 // 与 PrometheusMetrics.buckets 对应, 非累计
+}
+
+// NewPrometheusMetrics 创建 PrometheusMetrics, namespace 不为空时作为指标名前缀, buckets 为空时使用 DefaultLatencyBuckets
+func NewPrometheusMetrics(namespace string, buckets ...float64) *PrometheusMetrics {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	b := append([]float64(nil), buckets...)
+	sort.Float64s(b)
+	if namespace != "" {
+		namespace += "_"
+	}
+	return &PrometheusMetrics{
+		namespace: namespace,
+		buckets:   b,
+		requests:  make(map[statusLabels]uint64),
+		series:    make(map[MetricLabels]*promSeries),
+	}
+}
+
+func (p *PrometheusMetrics) get(labels MetricLabels) *promSeries {
+	s, ok := p.series[labels]
+	if !ok {
+		s = &promSeries{buckets: make([]uint64, len(p.buckets))}
+		p.series[labels] = s
+	}
+	return s
+}
+
+// InFlight ...
+func (p *PrometheusMetrics) InFlight(labels MetricLabels, delta int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.get(labels).inFlight += int64(delta)
+}
+
+// ObserveRequest ...
+func (p *PrometheusMetrics) ObserveRequest(m RequestMetric) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.requests[statusLabels{MetricLabels: m.MetricLabels, class: m.StatusClass()}]++
+
+	s := p.get(m.MetricLabels)
+	s.bytesSent += m.BytesSent
+	s.bytesReceived += m.BytesReceived
+	seconds := m.Duration.Seconds()
+	s.count++
+	s.sum += seconds
+	if i := sort.SearchFloat64s(p.buckets, seconds); i < len(p.buckets) {
+		s.buckets[i]++
+	}
+}
+
+// ObserveRetry ...
+func (p *PrometheusMetrics) ObserveRetry(labels MetricLabels) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.get(labels).retries++
+}
+
+// ServeHTTP 以 Prometheus 文本格式(0.0.4)输出指标
+func (p *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(p.Bytes())
+}
+
+// Bytes 获取 Prometheus 文本格式的指标
+func (p *PrometheusMetrics) Bytes() []byte {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var buf bytes.Buffer
+	ns := p.namespace
+
+	requests := make([]statusLabels, 0, len(p.requests))
+	for k := range p.requests {
+		requests = append(requests, k)
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		if requests[i].MetricLabels != requests[j].MetricLabels {
+			return lessLabels(requests[i].MetricLabels, requests[j].MetricLabels)
+		}
+		return requests[i].class < requests[j].class
+	})
+	series := make([]MetricLabels, 0, len(p.series))
+	for k := range p.series {
+		series = append(series, k)
+	}
+	sort.Slice(series, func(i, j int) bool {
+		return lessLabels(series[i], series[j])
+	})
+
+	writeHeader(&buf, ns+"http_client_requests_total", "counter", "Total number of HTTP requests sent.")
+	for _, k := range requests {
+		fmt.Fprintf(&buf, "%shttp_client_requests_total{%s,status_class=%q} %d\n", ns, promLabels(k.MetricLabels), k.class, p.requests[k])
+	}
+
+	name := ns + "http_client_request_duration_seconds"
+	writeHeader(&buf, name, "histogram", "HTTP request latency in seconds.")
+	for _, k := range series {
+		s, labels := p.series[k], promLabels(k)
+		var cumulative uint64
+		for i, le := range p.buckets {
+			cumulative += s.buckets[i]
+			fmt.Fprintf(&buf, "%s_bucket{%s,le=\"%s\"} %d\n", name, labels, formatFloat(le), cumulative)
+		}
+		fmt.Fprintf(&buf, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, s.count)
+		fmt.Fprintf(&buf, "%s_sum{%s} %s\n", name, labels, formatFloat(s.sum))
+		fmt.Fprintf(&buf, "%s_count{%s} %d\n", name, labels, s.count)
+	}
+
+	gauges := []struct {
+		name, typ, help string
+		value           func(s *promSeries) string
+	}{
+		{"http_client_in_flight_requests", "gauge", "Number of HTTP requests in flight.",
+			func(s *promSeries) string { return strconv.FormatInt(s.inFlight, 10) }},
+		{"http_client_retries_total", "counter", "Total number of HTTP request retries.",
+			func(s *promSeries) string { return strconv.FormatUint(s.retries, 10) }},
+		{"http_client_request_bytes_total", "counter", "Total bytes of HTTP request bodies sent.",
+			func(s *promSeries) string { return strconv.FormatInt(s.bytesSent, 10) }},
+		{"http_client_response_bytes_total", "counter", "Total bytes of HTTP response bodies received.",
+			func(s *promSeries) string { return strconv.FormatInt(s.bytesReceived, 10) }},
+	}
+	for _, g := range gauges {
+		writeHeader(&buf, ns+g.name, g.typ, g.help)
+		for _, k := range series {
+			fmt.Fprintf(&buf, "%s%s{%s} %s\n", ns, g.name, promLabels(k), g.value(p.series[k]))
+		}
+	}
+
+	return buf.Bytes()
+}
+
+func writeHeader(buf *bytes.Buffer, name string, typ string, help string) {
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func lessLabels(a MetricLabels, b MetricLabels) bool {
+	if a.Host != b.Host {
+		return a.Host < b.Host
+	}
+	if a.Method != b.Method {
+		return a.Method < b.Method
+	}
+	return a.Route < b.Route
+}
+
+var promEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func promLabels(l MetricLabels) string {
+	return `host="` + promEscaper.Replace(l.Host) + `",method="` + promEscaper.Replace(l.Method) + `",route="` + promEscaper.Replace(l.Route) + `"`
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
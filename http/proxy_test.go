@@ -0,0 +1,217 @@
+package http
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"golang.org/x/net/proxy"
+)
+
+// serveTestSOCKS5 本地 socks5 代理替身, 仅支持 CONNECT, user 不为空时要求用户名密码认证
+func serveTestSOCKS5(conn net.Conn, user string, password string) {
+	defer conn.Close()
+
+	buf := make([]byte, 256)
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return
+	}
+	if _, err := io.ReadFull(conn, buf[:buf[1]]); err != nil {
+		return
+	}
+
+	if user == "" {
+		conn.Write([]byte{5, 0})
+	} else {
+		conn.Write([]byte{5, 2})
+		if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+			return
+		}
+		u := make([]byte, buf[1])
+		io.ReadFull(conn, u)
+		io.ReadFull(conn, buf[:1])
+		p := make([]byte, buf[0])
+		io.ReadFull(conn, p)
+		if string(u) != user || string(p) != password {
+			conn.Write([]byte{1, 1})
+			return
+		}
+		conn.Write([]byte{1, 0})
+	}
+
+	if _, err := io.ReadFull(conn, buf[:4]); err != nil {
+		return
+	}
+	var host string
+	switch buf[3] {
+	case 1:
+		io.ReadFull(conn, buf[:4])
+		host = net.IP(buf[:4]).String()
+	case 3:
+		io.ReadFull(conn, buf[:1])
+		name := make([]byte, buf[0])
+		io.ReadFull(conn, name)
+		host = string(name)
+	default:
+		return
+	}
+	io.ReadFull(conn, buf[:2])
+	port := binary.BigEndian.Uint16(buf[:2])
+
+	target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(port))))
+	if err != nil {
+		conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer target.Close()
+	conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+
+	go io.Copy(target, conn)
+	io.Copy(conn, target)
+}
+
+// doRequest 发送请求并以文本读取响应 body, 出错时测试失败
+func doRequest(t *testing.T, c *Client, method string, rawURL string, reqOpts []RequestOptions, opts ...DoOptions) (*Response, string) {
+	t.Helper()
+
+	req, err := c.NewRequest(method, append([]RequestOptions{WithURL(rawURL)}, reqOpts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var text string
+	resp, err := c.Do(req, append(opts[:len(opts):len(opts)], WithResponseBodyData(&text))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, text
+}
+
+// doText 发送 GET 请求并返回响应 body 文本
+func doText(t *testing.T, c *Client, rawURL string, opts ...DoOptions) string {
+	t.Helper()
+
+	_, text := doRequest(t, c, http.MethodGet, rawURL, nil, opts...)
+	return text
+}
+
+func TestClientProxy(t *testing.T) {
+	// 代理替身不转发请求, 直接返回 "name host Proxy-Authorization"
+	p1 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", TextPlain)
+		w.Write([]byte("p1 " + r.Host + " " + r.Header.Get("Proxy-Authorization")))
+	}))
+	defer p1.Close()
+	p2 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", TextPlain)
+		w.Write([]byte("p2 " + r.Host + " " + r.Header.Get("Proxy-Authorization")))
+	}))
+	defer p2.Close()
+
+	c := NewClient(WithProxy(p1.URL), WithProxyAuth("user", "pass"))
+	if got := doText(t, c, "http://example.test/a"); got != "p1 example.test Basic dXNlcjpwYXNz" {
+		t.Fatalf("proxy response %q", got)
+	}
+	if got := doText(t, c, "http://example.test/a", WithRequestProxy(p2.URL)); got != "p2 example.test Basic dXNlcjpwYXNz" {
+		t.Fatalf("request proxy response %q", got)
+	}
+
+	direct := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", TextPlain)
+		w.Write([]byte("direct"))
+	}))
+	defer direct.Close()
+	if got := doText(t, c, direct.URL, WithRequestProxy("")); got != "direct" {
+		t.Fatalf("direct response %q", got)
+	}
+}
+
+func TestClientEnvironmentProxy(t *testing.T) {
+	t.Setenv("HTTP_PROXY", "http://127.0.0.1:3128")
+	t.Setenv("NO_PROXY", "internal.test")
+
+	c := NewClient(WithEnvironmentProxy())
+	ts := c.HTTPClient.Transport.(*http.Transport)
+	for target, want := range map[string]string{
+		"http://example.test/":  "http://127.0.0.1:3128",
+		"http://internal.test/": "",
+	} {
+		req, _ := http.NewRequest(http.MethodGet, target, nil)
+		u, err := ts.Proxy(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := ""
+		if u != nil {
+			got = u.String()
+		}
+		if got != want {
+			t.Fatalf("proxy for %s = %q, want %q", target, got, want)
+		}
+	}
+}
+
+func TestClientSOCKS5(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", TextPlain)
+		w.Write([]byte("via socks5"))
+	}))
+	defer target.Close()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	var hits int32
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&hits, 1)
+			go serveTestSOCKS5(conn, "user", "pass")
+		}
+	}()
+	addr := ln.Addr().String()
+
+	c := NewClient(WithSOCKS5Addr(addr, &proxy.Auth{User: "user", Password: "pass"}))
+	if got := doText(t, c, target.URL); got != "via socks5" || atomic.LoadInt32(&hits) != 1 {
+		t.Fatalf("socks5 response %q hits %d", got, hits)
+	}
+
+	req, _ := c.NewRequest(http.MethodGet, WithURL(target.URL))
+	if _, err := c.Do(req, WithRequestProxy("http://127.0.0.1:1")); !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("expect ErrInvalidConfig, got %v", err)
+	}
+
+	u := url.URL{Scheme: "socks5", User: url.UserPassword("user", "pass"), Host: addr}
+	c = NewClient(WithProxy(u.String()))
+	if got := doText(t, c, target.URL); got != "via socks5" || atomic.LoadInt32(&hits) != 2 {
+		t.Fatalf("socks5 proxy url response %q hits %d", got, hits)
+	}
+}
+
+func TestClientProxyConfigError(t *testing.T) {
+	cases := [][]Options{
+		{WithProxy("http://127.0.0.1:3128"), WithSOCKS5(proxy.Direct)},
+		{WithProxy("http://127.0.0.1:3128"), WithEnvironmentProxy()},
+		{WithProxy("ftp://127.0.0.1:21")},
+	}
+	for i, opts := range cases {
+		c := NewClient(opts...)
+		if !errors.Is(c.Err(), ErrInvalidConfig) {
+			t.Fatalf("case %d: expect ErrInvalidConfig, got %v", i, c.Err())
+		}
+		if _, err := c.NewRequest(http.MethodGet, WithURL("http://example.test")); !errors.Is(err, ErrInvalidConfig) {
+			t.Fatalf("case %d: NewRequest should return config error, got %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// ErrNoProxyAvailable 代理池中所有代理均已被剔除
+var ErrNoProxyAvailable = errors.New("no proxy available")
+
+// ProxyStrategy 代理池选择代理的策略
+type ProxyStrategy int
+
+const (
+	ProxyRoundRobin ProxyStrategy = iota // 轮询
+	ProxyRandom                          // 随机
+	ProxySticky                          // 同一 host 固定使用同一代理, 代理被剔除后重新选择
+)
+
+// ProxyPoolConfig 代理池配置
+type ProxyPoolConfig struct {
+	Proxies       []string      // 代理地址, 支持 http、https 与 socks5 协议, 格式同 WithProxy
+	Strategy      ProxyStrategy // 选择策略, 默认轮询
+	MaxFailures   int           // 连续失败次数达到该值时剔除代理, 默认 3
+	EvictDuration time.Duration // 代理被剔除的时间, 到期后重新加入, 默认 30s
+
+	// IsFailure 判断经由代理的请求是否失败, 默认网络错误与 407、502、504 响应视为失败
+	IsFailure func(resp *http.Response, err error) bool
+}
+
+// ProxyStatus 代理池中单个代理的状态
+type ProxyStatus struct {
+	URL          *url.URL
+	Failures     int       // 连续失败次数
+	EvictedUntil time.Time // 剔除到期时间, 零值表示未被剔除
+}
+
+// Healthy 代理当前是否可用
+func (s ProxyStatus) Healthy() bool {
+	return !s.EvictedUntil.After(time.Now())
+}
+
+// ProxyPool 代理池, 通过 WithProxyPool 配置到 client, 可被多个 client 共享
+// 每一次实际发送的请求(包括重试)都会重新选择代理, 请求失败时累计对应代理的失败次数
+type ProxyPool struct {
+	cfg ProxyPoolConfig
+
+	lock    sync.Mutex
+	entries []*proxyEntry
+	next    int
+	sticky  map[string]*proxyEntry
+	rand    *rand.Rand
+}
+
+// proxyEntry 代理池中的代理
+type proxyEntry struct {
+	url          *url.URL
+	failures     int
+	evictedUntil time.Time
+}
+
+// NewProxyPool 创建代理池, 代理地址无效时返回的 error 满足 errors.Is(err, ErrInvalidConfig)
+func NewProxyPool(cfg ProxyPoolConfig) (*ProxyPool, error) {
+	if len(cfg.Proxies) == 0 {
+		return nil, fmt.Errorf("%w: proxy pool is empty", ErrInvalidConfig)
+	}
+	if cfg.MaxFailures <= 0 {
+		cfg.MaxFailures = 3
+	}
+	if cfg.EvictDuration <= 0 {
+		cfg.EvictDuration = 30 * time.Second
+	}
+	if cfg.IsFailure == nil {
+		cfg.IsFailure = func(resp *http.Response, err error) bool {
+			if err != nil {
+				return true
+			}
+			switch resp.StatusCode {
+			case http.StatusProxyAuthRequired, http.StatusBadGateway, http.StatusGatewayTimeout:
+				return true
+			}
+			return false
+		}
+	}
+
+	p := &ProxyPool{
+		cfg:    cfg,
+		sticky: make(map[string]*proxyEntry),
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	for _, raw := range cfg.Proxies {
+		u, err := parseProxyURL(raw)
+		if err != nil {
+			return nil, err
+		}
+		p.entries = append(p.entries, &proxyEntry{url: u})
+	}
+	return p, nil
+}
+
+// Status 获取代理池中所有代理的状态
+func (p *ProxyPool) Status() []ProxyStatus {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	status := make([]ProxyStatus, 0, len(p.entries))
+	for _, e := range p.entries {
+		status = append(status, ProxyStatus{URL: e.url, Failures: e.failures, EvictedUntil: e.evictedUntil})
+	}
+	return status
+}
+
+// pick 依据策略选择一个未被剔除的代理
+func (p *ProxyPool) pick(host string) (*proxyEntry, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	now := time.Now()
+	available := make([]*proxyEntry, 0, len(p.entries))
+	for _, e := range p.entries {
+		if !e.evictedUntil.After(now) {
+			available = append(available, e)
+		}
+	}
+	if len(available) == 0 {
+		return nil, ErrNoProxyAvailable
+	}
+
+	switch p.cfg.Strategy {
+	case ProxyRandom:
+		return available[p.rand.Intn(len(available))], nil
+	case ProxySticky:
+		if e, ok := p.sticky[host]; ok && !e.evictedUntil.After(now) {
+			return e, nil
+		}
+		e := available[p.rand.Intn(len(available))]
+		p.sticky[host] = e
+		return e, nil
+	}
+
+	// 轮询时跳过被剔除的代理
+	for {
+		e := p.entries[p.next%len(p.entries)]
+		p.next = (p.next + 1) % len(p.entries)
+		if !e.evictedUntil.After(now) {
+			return e, nil
+		}
+	}
+}
+
+// report 记录代理的请求结果, 连续失败达到 MaxFailures 时剔除代理
+func (p *ProxyPool) report(e *proxyEntry, success bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if success {
+		e.failures = 0
+		return
+	}
+	e.failures++
+	if e.failures >= p.cfg.MaxFailures {
+		e.failures = 0
+		e.evictedUntil = time.Now().Add(p.cfg.EvictDuration)
+	}
+}
+
+// Check 主动检查所有代理: 经由每个代理请求 targetURL, 成功的代理立即恢复, 失败的代理立即被剔除
+// 可定期调用以提前发现失效代理, ctx 被取消时不更新代理状态
+func (p *ProxyPool) Check(ctx context.Context, targetURL string) error {
+	p.lock.Lock()
+	entries := append([]*proxyEntry(nil), p.entries...)
+	p.lock.Unlock()
+
+	var wg sync.WaitGroup
+	results := make([]bool, len(entries))
+	for i, e := range entries {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, targetURL, nil)
+		if err != nil {
+			return fmt.Errorf("http.NewRequest err %w", err)
+		}
+
+		wg.Add(1)
+		go func(i int, e *proxyEntry, req *http.Request) {
+			defer wg.Done()
+
+			ts := (&transportOptions{}).newTransport()
+			ts.Proxy = http.ProxyURL(e.url)
+			defer ts.CloseIdleConnections()
+
+			resp, err := ts.RoundTrip(req)
+			if err == nil {
+				io.Copy(ioutil.Discard, resp.Body)
+				resp.Body.Close()
+			}
+			results[i] = !p.cfg.IsFailure(resp, err)
+		}(i, e, req)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for i, e := range entries {
+		e.failures = 0
+		if results[i] {
+			e.evictedUntil = time.Time{}
+		} else {
+			e.evictedUntil = time.Now().Add(p.cfg.EvictDuration)
+		}
+	}
+	return nil
+}
+
+// middleware 为请求选择代理, 已通过 WithRequestProxy 指定代理的请求不经过代理池
+func (p *ProxyPool) middleware(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if _, ok := req.Context().Value(requestProxyKey{}).(requestProxy); ok {
+			return next.RoundTrip(req)
+		}
+
+		e, err := p.pick(req.URL.Host)
+		if err != nil {
+			return nil, err
+		}
+
+		req = req.WithContext(context.WithValue(req.Context(), requestProxyKey{}, requestProxy{url: e.url}))
+		resp, err := next.RoundTrip(req)
+		if req.Context().Err() == nil {
+			p.report(e, !p.cfg.IsFailure(resp, err))
+		}
+		return resp, err
+	})
+}
@@ -0,0 +1,180 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// deadProxyAddr 返回一个已关闭的本地地址, 连接该地址会立即失败
+func deadProxyAddr(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+func TestClientProxyPoolRoundRobin(t *testing.T) {
+	var hits1, hits2 int32
+	p1 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits1, 1)
+		w.Header().Set("Content-Type", TextPlain)
+		w.Write([]byte("p1 " + r.Host))
+	}))
+	defer p1.Close()
+	p2 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits2, 1)
+		w.Header().Set("Content-Type", TextPlain)
+		w.Write([]byte("p2 " + r.Host))
+	}))
+	defer p2.Close()
+
+	pool, err := NewProxyPool(ProxyPoolConfig{Proxies: []string{p1.URL, p2.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient(WithProxyPool(pool))
+
+	for i, want := range []string{"p1", "p2", "p1", "p2"} {
+		req, _ := c.NewRequest(http.MethodGet, WithURL("http://example.test/"))
+		var text string
+		resp, err := c.Do(req, WithResponseBodyData(&text))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if text != want+" example.test" {
+			t.Fatalf("request %d: response %q, want proxy %s", i, text, want)
+		}
+		wantURL := p1.URL
+		if want == "p2" {
+			wantURL = p2.URL
+		}
+		if resp.Proxy() == nil || resp.Proxy().String() != wantURL {
+			t.Fatalf("request %d: Response.Proxy() = %v, want %s", i, resp.Proxy(), wantURL)
+		}
+	}
+	if atomic.LoadInt32(&hits1) != 2 || atomic.LoadInt32(&hits2) != 2 {
+		t.Fatalf("hits p1=%d p2=%d", hits1, hits2)
+	}
+
+	p3 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", TextPlain)
+		w.Write([]byte("p3 " + r.Host))
+	}))
+	defer p3.Close()
+	if got := doText(t, c, "http://example.test/", WithRequestProxy(p3.URL)); got != "p3 example.test" {
+		t.Fatalf("request proxy should bypass pool, got %q", got)
+	}
+}
+
+func TestClientProxyPoolEviction(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", TextPlain)
+		w.Write([]byte("ok"))
+	}))
+	defer target.Close()
+
+	// 代理替身不转发请求, 直接返回 "ok"
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", TextPlain)
+		w.Write([]byte("ok"))
+	}))
+	defer healthy.Close()
+	dead := "http://" + deadProxyAddr(t)
+	pool, err := NewProxyPool(ProxyPoolConfig{
+		Proxies:       []string{dead, healthy.URL},
+		MaxFailures:   1,
+		EvictDuration: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	policy := DefaultRetryPolicy()
+	policy.BaseDelay = time.Millisecond
+	c := NewClient(WithProxyPool(pool), WithRetry(policy))
+
+	// 首次请求经由失效代理失败, 重试时换用可用代理
+	req, _ := c.NewRequest(http.MethodGet, WithURL(target.URL))
+	var text string
+	resp, err := c.Do(req, WithResponseBodyData(&text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "ok" || resp.Proxy() == nil || resp.Proxy().String() != healthy.URL {
+		t.Fatalf("response %q via %v", text, resp.Proxy())
+	}
+
+	status := pool.Status()
+	if status[0].Healthy() || !status[1].Healthy() {
+		t.Fatalf("dead proxy should be evicted: %+v", status)
+	}
+
+	// 失效代理被剔除后不再被选择
+	for i := 0; i < 3; i++ {
+		if got := doText(t, c, target.URL); got != "ok" {
+			t.Fatalf("response %q", got)
+		}
+	}
+
+	// 主动检查剔除失效代理后无可用代理
+	pool2, _ := NewProxyPool(ProxyPoolConfig{Proxies: []string{dead}, Strategy: ProxySticky})
+	if err := pool2.Check(context.Background(), target.URL); err != nil {
+		t.Fatal(err)
+	}
+	c = NewClient(WithProxyPool(pool2), WithRetry(policy))
+	req, _ = c.NewRequest(http.MethodGet, WithURL(target.URL))
+	if _, err := c.Do(req); !errors.Is(err, ErrNoProxyAvailable) {
+		t.Fatalf("expect ErrNoProxyAvailable, got %v", err)
+	}
+}
+
+func TestClientProxyPoolSticky(t *testing.T) {
+	p1 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", TextPlain)
+		w.Write([]byte("p1"))
+	}))
+	defer p1.Close()
+	p2 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", TextPlain)
+		w.Write([]byte("p2"))
+	}))
+	defer p2.Close()
+
+	pool, _ := NewProxyPool(ProxyPoolConfig{Proxies: []string{p1.URL, p2.URL}, Strategy: ProxySticky})
+	c := NewClient(WithProxyPool(pool))
+
+	first := doText(t, c, "http://a.test/")
+	for i := 0; i < 5; i++ {
+		if got := doText(t, c, "http://a.test/"); got != first {
+			t.Fatalf("sticky proxy changed: %q != %q", got, first)
+		}
+	}
+}
+
+func TestClientProxyPoolConfigError(t *testing.T) {
+	if _, err := NewProxyPool(ProxyPoolConfig{Proxies: []string{"ftp://127.0.0.1:21"}}); !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("expect ErrInvalidConfig, got %v", err)
+	}
+
+	pool, _ := NewProxyPool(ProxyPoolConfig{Proxies: []string{"http://127.0.0.1:3128"}})
+	c := NewClient(WithProxyPool(pool), WithProxy("http://127.0.0.1:3128"))
+	if !errors.Is(c.Err(), ErrInvalidConfig) {
+		t.Fatalf("expect ErrInvalidConfig, got %v", c.Err())
+	}
+
+	c = NewClient()
+	req, _ := c.NewRequest(http.MethodGet, WithURL("http://example.test"))
+	resp := &Response{Request: req}
+	if resp.Proxy() != nil {
+		t.Fatalf("unexpected proxy %v", resp.Proxy())
+	}
+}
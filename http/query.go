@@ -0,0 +1,322 @@
+package http
+
+import (
+	"encoding"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// QueryEncoder 自定义类型的 query 编码方式, 将自身以 key 编码后写入 values
+type QueryEncoder interface {
+	EncodeQuery(key string, values url.Values) error
+}
+
+var (
+	timeType         = reflect.TypeOf(time.Time{})
+	queryEncoderType = reflect.TypeOf((*QueryEncoder)(nil)).Elem()
+	textMarshalType  = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// EncodeValues 将 v 编码为 url.Values, 可用于 query 与 application/x-www-form-urlencoded body
+// v 支持 url.Values、map[string]string、map[string][]string、map[string]interface{} 以及结构体(或其指针)
+//
+// 结构体字段通过 `url:"name,opts"` 标签配置, 未配置时使用字段名, "-" 表示忽略, 支持的 opts:
+//
+//	omitempty 零值时忽略
+//	comma     切片以逗号拼接为一个值, 默认重复 key
+//	unix      time.Time 编码为秒级时间戳, unixmilli 为毫秒级时间戳
+//
+// time.Time 默认以 RFC3339 编码, 可通过 `layout:"2006-01-02"` 标签指定格式
+// 切片重复 key 编码, 指针取其指向的值, nil 指针忽略, 嵌套结构体编码为 "parent[child]", 匿名结构体字段展开
+// 实现了 QueryEncoder 或 encoding.TextMarshaler 的类型使用自定义编码
+func EncodeValues(v interface{}) (url.Values, error) {
+	values := make(url.Values)
+
+	switch d := v.(type) {
+	case nil:
+		return values, nil
+	case url.Values:
+		for k, vs := range d {
+			values[k] = append([]string(nil), vs...)
+		}
+		return values, nil
+	case map[string][]string:
+		return EncodeValues(url.Values(d))
+	case map[string]string:
+		for k, s := range d {
+			values.Set(k, s)
+		}
+		return values, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return values, nil
+		}
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Struct:
+		return values, encodeStruct(values, "", rv)
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("encode values unsupported map key type %v", rv.Type().Key())
+		}
+		iter := rv.MapRange()
+		for iter.Next() {
+			if err := encodeValue(values, iter.Key().String(), iter.Value(), tagOptions{}); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	}
+
+	return nil, fmt.Errorf("encode values unsupported type %T", v)
+}
+
+// tagOptions 结构体字段的编码配置
+type tagOptions struct {
+	omitEmpty bool
+	comma     bool
+	unix      bool
+	unixMilli bool
+	layout    string
+}
+
+func encodeStruct(values url.Values, prefix string, rv reflect.Value) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous { // 未导出字段
+			continue
+		}
+
+		tag := sf.Tag.Get("url")
+		if tag == "-" {
+			continue
+		}
+		name, opts := parseTag(tag)
+		opts.layout = sf.Tag.Get("layout")
+
+		fv := rv.Field(i)
+		if sf.Anonymous && name == "" {
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+				if fv.IsNil() {
+					continue
+				}
+				fv = fv.Elem()
+			}
+			if ft.Kind() == reflect.Struct && ft != timeType && !implementsEncoder(ft) {
+				if err := encodeStruct(values, prefix, fv); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if sf.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = sf.Name
+		}
+		if prefix != "" {
+			name = prefix + "[" + name + "]"
+		}
+
+		if opts.omitEmpty && fv.IsZero() {
+			continue
+		}
+		if err := encodeValue(values, name, fv, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseTag(tag string) (string, tagOptions) {
+	var opts tagOptions
+	parts := strings.Split(tag, ",")
+	for _, o := range parts[1:] {
+		switch strings.TrimSpace(o) {
+		case "omitempty":
+			opts.omitEmpty = true
+		case "comma":
+			opts.comma = true
+		case "unix":
+			opts.unix = true
+		case "unixmilli":
+			opts.unixMilli = true
+		}
+	}
+	return parts[0], opts
+}
+
+func implementsEncoder(t reflect.Type) bool {
+	pt := reflect.PtrTo(t)
+	return t.Implements(queryEncoderType) || pt.Implements(queryEncoderType) ||
+		t.Implements(textMarshalType) || pt.Implements(textMarshalType)
+}
+
+// encodeValue 将 rv 以 key 编码后写入 values
+func encodeValue(values url.Values, key string, rv reflect.Value, opts tagOptions) error {
+	if !rv.IsValid() {
+		return nil
+	}
+
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		if enc, ok := rv.Interface().(QueryEncoder); ok && rv.Kind() == reflect.Ptr {
+			return enc.EncodeQuery(key, values)
+		}
+		rv = rv.Elem()
+	}
+
+	var value interface{}
+	if rv.CanAddr() {
+		// 指针接收者实现的 QueryEncoder 与 encoding.TextMarshaler
+		value = rv.Addr().Interface()
+		if _, ok := value.(QueryEncoder); !ok {
+			if _, ok = value.(encoding.TextMarshaler); !ok || rv.Type() == timeType {
+				value = rv.Interface()
+			}
+		}
+	} else {
+		value = rv.Interface()
+	}
+
+	switch d := value.(type) {
+	case QueryEncoder:
+		return d.EncodeQuery(key, values)
+	case time.Time:
+		values.Add(key, formatTime(d, opts))
+		return nil
+	case []byte:
+		values.Add(key, string(d))
+		return nil
+	case encoding.TextMarshaler:
+		b, err := d.MarshalText()
+		if err != nil {
+			return fmt.Errorf("encode value %s err %w", key, err)
+		}
+		values.Add(key, string(b))
+		return nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		if opts.comma {
+			s := make([]string, 0, rv.Len())
+			for i := 0; i < rv.Len(); i++ {
+				tmp := make(url.Values)
+				if err := encodeValue(tmp, key, rv.Index(i), tagOptions{layout: opts.layout, unix: opts.unix, unixMilli: opts.unixMilli}); err != nil {
+					return err
+				}
+				s = append(s, tmp[key]...)
+			}
+			values.Add(key, strings.Join(s, ","))
+			return nil
+		}
+		for i := 0; i < rv.Len(); i++ {
+			if err := encodeValue(values, key, rv.Index(i), opts); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Struct:
+		return encodeStruct(values, key, rv)
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("encode value %s unsupported map key type %v", key, rv.Type().Key())
+		}
+		iter := rv.MapRange()
+		for iter.Next() {
+			if err := encodeValue(values, key+"["+iter.Key().String()+"]", iter.Value(), opts); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.String:
+		values.Add(key, rv.String())
+	case reflect.Bool:
+		values.Add(key, strconv.FormatBool(rv.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		values.Add(key, strconv.FormatInt(rv.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		values.Add(key, strconv.FormatUint(rv.Uint(), 10))
+	case reflect.Float32:
+		values.Add(key, strconv.FormatFloat(rv.Float(), 'f', -1, 32))
+	case reflect.Float64:
+		values.Add(key, strconv.FormatFloat(rv.Float(), 'f', -1, 64))
+	default:
+		return fmt.Errorf("encode value %s unsupported type %v", key, rv.Type())
+	}
+	return nil
+}
+
+func formatTime(t time.Time, opts tagOptions) string {
+	switch {
+	case opts.unix:
+		return strconv.FormatInt(t.Unix(), 10)
+	case opts.unixMilli:
+		return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+	case opts.layout != "":
+		return t.Format(opts.layout)
+	}
+	return t.Format(time.RFC3339)
+}
+
+// mergeQuery 将 sources 编码后追加到 rawURL 已有的 query 之后, defaults 中的 key 不存在时使用默认值
+// rawURL 中已有的 query 保持原样, 不重新排序与编码, 避免改变顺序敏感或预签名的 url
+func mergeQuery(rawURL string, defaults url.Values, sources []interface{}) (string, error) {
+	if len(sources) == 0 && len(defaults) == 0 {
+		return rawURL, nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("parse url err %w", err)
+	}
+
+	added := make(url.Values)
+	for _, src := range sources {
+		values, err := EncodeValues(src)
+		if err != nil {
+			return "", err
+		}
+		for k, vs := range values {
+			added[k] = append(added[k], vs...)
+		}
+	}
+	if len(defaults) > 0 {
+		existing := u.Query()
+		for k, vs := range defaults {
+			if _, ok := existing[k]; ok {
+				continue
+			}
+			if _, ok := added[k]; !ok {
+				added[k] = vs
+			}
+		}
+	}
+	if len(added) == 0 {
+		return rawURL, nil
+	}
+
+	if u.RawQuery == "" {
+		u.RawQuery = added.Encode()
+	} else {
+		u.RawQuery += "&" + added.Encode()
+	}
+	return u.String(), nil
+}
@@ -0,0 +1,182 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited 未配置等待令牌时, 令牌不足请求直接失败
+var ErrRateLimited = errors.New("rate limited")
+
+// RateLimit 令牌桶限流配置
+// 收到带有 Retry-After 的 429 响应时, 对应的令牌桶暂停发放令牌直到 Retry-After 到期
+type RateLimit struct {
+	Rate    float64 // 每秒发放的令牌数
+	Burst   int     // 令牌桶容量, 默认为 Rate 向上取整
+	PerHost bool    // 是否按 host 分别限流, 默认所有请求共用一个令牌桶
+	Wait    bool    // 令牌不足时是否等待, 等待受请求 context 控制; 不等待时返回 ErrRateLimited
+}
+
+// rateLimiter 按 host 选择令牌桶
+type rateLimiter struct {
+	defaults *RateLimit
+	hosts    map[string]RateLimit
+
+	lock    sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func newRateLimiter(defaults *RateLimit, hosts map[string]RateLimit) *rateLimiter {
+	return &rateLimiter{
+		defaults: defaults,
+		hosts:    hosts,
+		buckets:  make(map[string]*tokenBucket),
+	}
+}
+
+// bucket 获取 host 对应的令牌桶, 不限流时返回 nil
+func (l *rateLimiter) bucket(host string) (*tokenBucket, bool) {
+	limit, ok := l.hosts[host]
+	key := "host:" + host
+	if !ok {
+		if l.defaults == nil {
+			return nil, false
+		}
+		limit = *l.defaults
+		if !limit.PerHost {
+			key = ""
+		}
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = newTokenBucket(limit, time.Now())
+		l.buckets[key] = b
+	}
+	return b, limit.Wait
+}
+
+func (l *rateLimiter) middleware(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		b, wait := l.bucket(req.URL.Host)
+		if b == nil {
+			return next.RoundTrip(req)
+		}
+
+		d, ok := b.reserve(time.Now(), wait)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrRateLimited, req.URL.Host)
+		}
+		if d > 0 {
+			timer := time.NewTimer(d)
+			select {
+			case <-req.Context().Done():
+				timer.Stop()
+				b.cancel()
+				return nil, req.Context().Err()
+			case <-timer.C:
+			}
+		}
+
+		resp, err := next.RoundTrip(req)
+		if err == nil && resp.StatusCode == http.StatusTooManyRequests {
+			if d, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+				b.pause(time.Now().Add(d))
+			}
+		}
+		return resp, err
+	})
+}
+
+// tokenBucket 令牌桶
+type tokenBucket struct {
+	rate  float64
+	burst float64
+
+	lock        sync.Mutex
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+}
+
+func newTokenBucket(limit RateLimit, now time.Time) *tokenBucket {
+	burst := float64(limit.Burst)
+	if burst <= 0 {
+		burst = math.Max(1, math.Ceil(limit.Rate))
+	}
+	return &tokenBucket{
+		rate:   limit.Rate,
+		burst:  burst,
+		tokens: burst,
+		last:   now,
+	}
+}
+
+// reserve 获取一个令牌, 返回需要等待的时间
+// wait 为 false 且令牌不足时返回 false; wait 为 true 时预占令牌, 等待取消后需调用 cancel 归还
+func (b *tokenBucket) reserve(now time.Time, wait bool) (time.Duration, bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.advance(now)
+
+	var d time.Duration
+	if b.pausedUntil.After(now) {
+		if !wait {
+			return 0, false
+		}
+		d = b.pausedUntil.Sub(now)
+	}
+
+	if b.tokens < 1 && !wait {
+		return 0, false
+	}
+	b.tokens--
+	if b.tokens < 0 {
+		if b.rate <= 0 {
+			b.tokens++
+			return 0, false
+		}
+		d += time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+	return d, true
+}
+
+// cancel 归还 reserve 预占的令牌
+func (b *tokenBucket) cancel() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.tokens = math.Min(b.burst, b.tokens+1)
+}
+
+// pause 暂停发放令牌直到 until, 恢复后从空桶开始按速率发放
+func (b *tokenBucket) pause(until time.Time) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if !until.After(b.pausedUntil) {
+		return
+	}
+	b.pausedUntil = until
+	if b.tokens > 0 {
+		b.tokens = 0
+	}
+	b.last = until
+}
+
+// advance 按时间补充令牌, 调用方需持有锁
+func (b *tokenBucket) advance(now time.Time) {
+	if now.Before(b.last) {
+		return
+	}
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+}
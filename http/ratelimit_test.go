@@ -0,0 +1,75 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	b := newTokenBucket(RateLimit{Rate: 10, Burst: 2}, now)
+
+	for i := 0; i < 2; i++ {
+		if d, ok := b.reserve(now, false); !ok || d != 0 {
+			t.Fatalf("burst token %d: %v %v", i, d, ok)
+		}
+	}
+	if _, ok := b.reserve(now, false); ok {
+		t.Fatalf("expect no token without wait")
+	}
+	if d, ok := b.reserve(now, true); !ok || d != 100*time.Millisecond {
+		t.Fatalf("expect wait 100ms, got %v %v", d, ok)
+	}
+	b.cancel()
+
+	now = now.Add(100 * time.Millisecond)
+	b.pause(now.Add(time.Second))
+	if _, ok := b.reserve(now, false); ok {
+		t.Fatalf("paused bucket should not grant token")
+	}
+	if d, ok := b.reserve(now, true); !ok || d != time.Second+100*time.Millisecond {
+		t.Fatalf("expect wait 1.1s, got %v %v", d, ok)
+	}
+}
+
+func TestClientRateLimit(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(WithRateLimit(RateLimit{Rate: 1000, Burst: 10}))
+	req, _ := c.NewRequest(http.MethodGet, WithURL(srv.URL))
+	resp, err := c.Do(req)
+	if err != nil || resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expect 429, got %v %v", resp, err)
+	}
+
+	req, _ = c.NewRequest(http.MethodGet, WithURL(srv.URL))
+	if _, err = c.Do(req); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expect ErrRateLimited after 429, got %v", err)
+	}
+
+	c = NewClient(WithHostRateLimit(srv.Listener.Addr().String(), RateLimit{Rate: 1, Burst: 1, Wait: true}))
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		req, _ = c.NewRequestWithContext(ctx, http.MethodGet, WithURL(srv.URL))
+		_, err = c.Do(req)
+		cancel()
+		if i == 0 && err != nil {
+			t.Fatal(err)
+		}
+		if i == 1 && !IsTimeout(err) {
+			t.Fatalf("waiting for token should honour context, got %v", err)
+		}
+	}
+}
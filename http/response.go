@@ -1,7 +1,10 @@
 package http
 
 import (
+	"context"
 	"net/http"
+	"net/url"
+	"sync"
 )
 
 // Response ...
@@ -11,3 +14,81 @@ type Response http.Response
 func (r *Response) IsOK() bool {
 	return r.StatusCode >= 100 && r.StatusCode < 300
 }
+
+// Proxy 获取实际发送请求使用的代理, 未使用代理时返回 nil
+func (r *Response) Proxy() *url.URL {
+	meta := r.meta()
+	if meta == nil {
+		return nil
+	}
+
+	meta.lock.Lock()
+	defer meta.lock.Unlock()
+	return meta.proxy
+}
+
+// FromCache 响应是否来自 WithCache 配置的缓存(包括重新验证后使用的缓存)
+func (r *Response) FromCache() bool {
+	meta := r.meta()
+	if meta == nil {
+		return false
+	}
+
+	meta.lock.Lock()
+	defer meta.lock.Unlock()
+	return meta.fromCache
+}
+
+// Revalidated 响应是否为服务端返回 304 后使用的缓存
+func (r *Response) Revalidated() bool {
+	meta := r.meta()
+	if meta == nil {
+		return false
+	}
+
+	meta.lock.Lock()
+	defer meta.lock.Unlock()
+	return meta.revalidated
+}
+
+func (r *Response) meta() *responseMeta {
+	if r.Request == nil {
+		return nil
+	}
+	meta, _ := r.Request.Context().Value(responseMetaKey{}).(*responseMeta)
+	return meta
+}
+
+// responseMetaKey responseMeta 在请求 context 中的 key
+type responseMetaKey struct{}
+
+// responseMeta 请求执行过程中由 Transport 与中间件记录的信息, 通过 Response 的方法获取
+type responseMeta struct {
+	lock        sync.Mutex
+	proxy       *url.URL
+	fromCache   bool
+	revalidated bool
+}
+
+// withResponseMeta 在请求 context 中附加 responseMeta
+func withResponseMeta(req *http.Request) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), responseMetaKey{}, &responseMeta{}))
+}
+
+// setProxy 记录请求使用的代理
+func setProxy(req *http.Request, u *url.URL) {
+	if meta, ok := req.Context().Value(responseMetaKey{}).(*responseMeta); ok {
+		meta.lock.Lock()
+		meta.proxy = u
+		meta.lock.Unlock()
+	}
+}
+
+// setCacheStatus 记录响应是否来自缓存
+func setCacheStatus(req *http.Request, fromCache bool, revalidated bool) {
+	if meta, ok := req.Context().Value(responseMetaKey{}).(*responseMeta); ok {
+		meta.lock.Lock()
+		meta.fromCache, meta.revalidated = fromCache, revalidated
+		meta.lock.Unlock()
+	}
+}
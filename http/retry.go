@@ -0,0 +1,197 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryPolicy 重试策略
+type RetryPolicy struct {
+	MaxAttempts int           // 最大尝试次数(包含首次请求), 小于等于 1 时不重试
+	BaseDelay   time.Duration // 首次重试前的等待时间, 之后每次翻倍
+	MaxDelay    time.Duration // 单次等待的上限, 0 表示不限制(Retry-After 最多等待 1 小时); Retry-After 超过该值时不再重试
+	Jitter      float64       // 随机抖动比例, 取值 [0, 1], 实际等待时间在 [delay*(1-Jitter), delay] 之间
+
+	StatusCodes        []int // 需要重试的响应状态码
+	RetryNetworkErrors bool  // 网络错误是否重试, 调用方取消、超时、熔断、限流或代理池无可用代理不会重试
+	RetryNonIdempotent bool  // POST/PATCH 等非幂等请求是否重试, 带有 Idempotency-Key 请求头的请求视为幂等
+}
+
+// DefaultRetryPolicy 默认重试策略
+// 最多请求 3 次, 对 429/502/503/504 及网络错误重试
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:        3,
+		BaseDelay:          100 * time.Millisecond,
+		MaxDelay:           10 * time.Second,
+		Jitter:             0.2,
+		StatusCodes:        []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+		RetryNetworkErrors: true,
+	}
+}
+
+// idempotent 判断请求是否允许重试
+func (p *RetryPolicy) idempotent(req *http.Request) bool {
+	if p.RetryNonIdempotent || req.Header.Get("Idempotency-Key") != "" {
+		return true
+	}
+
+	switch req.Method {
+	case "", MethodGet, MethodHead, MethodOptions, MethodTrace, MethodPut, MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryable 判断本次请求结果是否需要重试
+func (p *RetryPolicy) retryable(req *http.Request, resp *http.Response, err error) bool {
+	if !p.idempotent(req) {
+		return false
+	}
+
+	if err != nil {
+		if req.Context().Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) ||
+			errors.Is(err, ErrCircuitOpen) || errors.Is(err, ErrRateLimited) || errors.Is(err, ErrNoProxyAvailable) {
+			return false
+		}
+		return p.RetryNetworkErrors
+	}
+
+	for _, code := range p.StatusCodes {
+		if resp.StatusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// delay 计算第 attempt 次请求失败后的等待时间, 返回 false 表示 Retry-After 超过 MaxDelay 不再重试
+func (p *RetryPolicy) delay(attempt int, resp *http.Response) (time.Duration, bool) {
+	if resp != nil {
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			if p.MaxDelay > 0 && d > p.MaxDelay {
+				return 0, false
+			}
+			return d, true
+		}
+	}
+
+	d := float64(p.BaseDelay) * math.Pow(2, float64(attempt-1))
+	if p.MaxDelay > 0 && d > float64(p.MaxDelay) {
+		d = float64(p.MaxDelay)
+	}
+	if p.Jitter > 0 {
+		d -= d * math.Min(p.Jitter, 1) * rand.Float64()
+	}
+
+	return time.Duration(d), true
+}
+
+// maxRetryAfter Retry-After 的上限, MaxDelay 为 0 时避免服务端要求无限期等待
+const maxRetryAfter = time.Hour
+
+// parseRetryAfter 解析 Retry-After, 支持秒数与 HTTP-date 两种格式, 超过 maxRetryAfter 时取 maxRetryAfter
+// 秒数超出 time.Duration 范围时视为无效
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 || int64(seconds) > math.MaxInt64/int64(time.Second) {
+			return 0, false
+		}
+		if d := time.Duration(seconds) * time.Second; d < maxRetryAfter {
+			return d, true
+		}
+		return maxRetryAfter, true
+	}
+
+	t, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if d := t.Sub(now); d > 0 {
+		if d > maxRetryAfter {
+			d = maxRetryAfter
+		}
+		return d, true
+	}
+	return 0, true
+}
+
+// rewindRequest 重新生成 body 以便再次发送请求, body 不可重放时返回 false
+func rewindRequest(req *http.Request) (*http.Request, bool) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, true
+	}
+	if req.GetBody == nil {
+		return nil, false
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, false
+	}
+
+	next := req.Clone(req.Context())
+	next.Body = body
+	return next, true
+}
+
+// drainBody 读完并关闭 body, 以便复用连接
+func drainBody(body io.ReadCloser) {
+	_, _ = io.CopyN(ioutil.Discard, body, 4<<10)
+	_ = body.Close()
+}
+
+// send 发送请求, 按重试策略重试
+// 请求级重试策略优先于 client 重试策略
+func (c *Client) send(hc *http.Client, req *http.Request, opt *doOptions) (*http.Response, error) {
+	policy := opt.retry
+	if policy == nil {
+		policy = c.opt.retry
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, err := hc.Do(req)
+		if policy == nil || attempt >= policy.MaxAttempts || !policy.retryable(req, resp, err) {
+			return resp, err
+		}
+
+		wait, ok := policy.delay(attempt, resp)
+		if !ok {
+			return resp, err
+		}
+		next, ok := rewindRequest(req)
+		if !ok {
+			return resp, err
+		}
+		if resp != nil {
+			drainBody(resp.Body)
+		}
+		if c.opt.metrics != nil {
+			c.opt.metrics.ObserveRetry(metricLabels(req))
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, fmt.Errorf("wait for retry attempt %d err %w", attempt+1, req.Context().Err())
+		case <-timer.C:
+		}
+
+		req = next
+	}
+}
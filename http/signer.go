@@ -0,0 +1,353 @@
+package http
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EmptyBodySHA256 空 body 的 SHA-256 摘要
+const EmptyBodySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// Signer 请求签名, 在每一次实际发送请求(包括重试与同 host 重定向)前调用, req 已复制, 可直接修改
+// bodyHash 为请求 body 的 SHA-256 摘要(小写十六进制), now 为签名时间
+type Signer interface {
+	Sign(req *http.Request, bodyHash string, now time.Time) error
+}
+
+// SignerFunc 函数形式的 Signer
+type SignerFunc func(req *http.Request, bodyHash string, now time.Time) error
+
+// Sign ...
+func (f SignerFunc) Sign(req *http.Request, bodyHash string, now time.Time) error {
+	return f(req, bodyHash, now)
+}
+
+// WithSigner 配置 client 的请求签名, 可被 WithRequestSigner 覆盖
+// 签名位于鉴权内层, 每次重试都会使用新的时间重新签名, body 通过 GetBody 重放计算摘要
+func WithSigner(signer Signer) Options {
+	return func(o *options) {
+		o.signer = signer
+	}
+}
+
+// WithRequestSigner 配置单次请求的签名, 覆盖 client 的请求签名
+func WithRequestSigner(signer Signer) DoOptions {
+	return func(o *doOptions) {
+		o.signer = signer
+	}
+}
+
+// signMiddleware 计算 body 摘要并签名, 重定向到其他 host 时不签名
+func signMiddleware(signer Signer) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if !authAllowed(req) {
+				return next.RoundTrip(req)
+			}
+
+			req = req.Clone(req.Context())
+			bodyHash, err := hashBody(req)
+			if err != nil {
+				return nil, fmt.Errorf("hash body err %w", err)
+			}
+			if err = signer.Sign(req, bodyHash, time.Now()); err != nil {
+				return nil, fmt.Errorf("sign request err %w", err)
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// hashBody 计算 body 的 SHA-256 摘要, 优先通过 GetBody 读取副本
+// body 不可重放时读入内存并替换 req.Body, 调用方需传入已复制的请求
+func hashBody(req *http.Request) (string, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return EmptyBodySHA256, nil
+	}
+
+	h := sha256.New()
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return "", err
+		}
+		defer body.Close()
+		if _, err = io.Copy(h, body); err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+
+	b, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return "", err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(b))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(b)), nil
+	}
+	h.Write(b)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// CanonicalFunc 生成待签名的规范请求
+// signedHeaders 为参与签名的请求头(小写, 已排序), timestamp 为签名时间
+type CanonicalFunc func(req *http.Request, signedHeaders []string, bodyHash string, timestamp string) string
+
+// HMACSigner 通用 HMAC-SHA256 签名
+// 签名前设置 TimestampHeader(unix 秒)与 BodyHashHeader, 然后对 Canonical 生成的规范请求签名, 结果写入 SignatureHeader:
+//
+//	HMAC-SHA256 Credential=<KeyID>, SignedHeaders=host;x-content-sha256;x-timestamp, Signature=<hex>
+type HMACSigner struct {
+	KeyID         string
+	Secret        []byte
+	SignedHeaders []string // 额外参与签名的请求头, host 与时间戳、摘要请求头始终参与签名
+
+	Canonical       CanonicalFunc // 默认 CanonicalRequest
+	TimestampHeader string        // 默认 X-Timestamp
+	BodyHashHeader  string        // 默认 X-Content-SHA256
+	SignatureHeader string        // 默认 X-Signature
+}
+
+// Sign 实现 Signer
+func (s *HMACSigner) Sign(req *http.Request, bodyHash string, now time.Time) error {
+	timestampHeader := defaultString(s.TimestampHeader, "X-Timestamp")
+	bodyHashHeader := defaultString(s.BodyHashHeader, "X-Content-SHA256")
+	canonical := s.Canonical
+	if canonical == nil {
+		canonical = CanonicalRequest
+	}
+
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	req.Header.Set(timestampHeader, timestamp)
+	req.Header.Set(bodyHashHeader, bodyHash)
+
+	signed := signedHeaderNames(append([]string{"host", timestampHeader, bodyHashHeader}, s.SignedHeaders...))
+	signature := hmacSHA256(s.Secret, canonical(req, signed, bodyHash, timestamp))
+
+	req.Header.Set(defaultString(s.SignatureHeader, "X-Signature"), fmt.Sprintf("HMAC-SHA256 Credential=%s, SignedHeaders=%s, Signature=%s",
+		s.KeyID, strings.Join(signed, ";"), hex.EncodeToString(signature)))
+	return nil
+}
+
+// CanonicalRequest HMACSigner 默认的规范请求, 各部分以换行分隔:
+// 请求方法、转义后的 path、排序后的 query、规范请求头、参与签名的请求头、body 摘要、签名时间
+func CanonicalRequest(req *http.Request, signedHeaders []string, bodyHash string, timestamp string) string {
+	return strings.Join([]string{
+		req.Method,
+		escapedPath(req.URL),
+		canonicalQuery(req.URL),
+		canonicalHeaders(req, signedHeaders),
+		strings.Join(signedHeaders, ";"),
+		bodyHash,
+		timestamp,
+	}, "\n")
+}
+
+// SigV4Signer 兼容 AWS Signature Version 4 的签名, 签名写入 Authorization 请求头
+type SigV4Signer struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string // 临时凭证的 token, 写入 X-Amz-Security-Token
+	Region          string
+	Service         string
+
+	ContentSHA256          bool // 是否设置 X-Amz-Content-Sha256 请求头, S3 需要开启
+	DisableURIPathEscaping bool // path 不再二次转义, S3 需要开启
+}
+
+// sigV4IgnoredHeaders 不参与 SigV4 签名的请求头
+var sigV4IgnoredHeaders = map[string]bool{
+	"authorization":   true,
+	"user-agent":      true,
+	"x-amzn-trace-id": true,
+	"expect":          true,
+	"connection":      true,
+}
+
+// Sign 实现 Signer
+func (s *SigV4Signer) Sign(req *http.Request, bodyHash string, now time.Time) error {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Del("Authorization")
+	req.Header.Set("X-Amz-Date", amzDate)
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+	if s.ContentSHA256 {
+		req.Header.Set("X-Amz-Content-Sha256", bodyHash)
+	}
+
+	names := []string{"host"}
+	for k := range req.Header {
+		if !sigV4IgnoredHeaders[strings.ToLower(k)] {
+			names = append(names, k)
+		}
+	}
+	signed := signedHeaderNames(names)
+
+	canonical := strings.Join([]string{
+		req.Method,
+		canonicalPath(req.URL, !s.DisableURIPathEscaping),
+		canonicalQuery(req.URL),
+		canonicalHeaders(req, signed),
+		strings.Join(signed, ";"),
+		bodyHash,
+	}, "\n")
+
+	scope := strings.Join([]string{date, s.Region, s.Service, "aws4_request"}, "/")
+	sum := sha256.Sum256([]byte(canonical))
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(sum[:])}, "\n")
+
+	key := []byte("AWS4" + s.SecretAccessKey)
+	for _, part := range []string{date, s.Region, s.Service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKeyID, scope, strings.Join(signed, ";"), signature))
+	return nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func defaultString(s string, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+// signedHeaderNames 请求头名称转为小写、去重并排序
+func signedHeaderNames(names []string) []string {
+	seen := make(map[string]bool, len(names))
+	signed := make([]string, 0, len(names))
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != "" && !seen[name] {
+			seen[name] = true
+			signed = append(signed, name)
+		}
+	}
+	sort.Strings(signed)
+	return signed
+}
+
+// requestHost 请求的 host, 服务端收到的请求 URL 中不包含 host
+func requestHost(req *http.Request) string {
+	if req.Host != "" {
+		return req.Host
+	}
+	return req.URL.Host
+}
+
+// canonicalHeaders 每行一个 "name:value", 多个值以逗号连接, 值去除首尾空白并合并连续空白
+func canonicalHeaders(req *http.Request, signedHeaders []string) string {
+	var b strings.Builder
+	for _, name := range signedHeaders {
+		var values []string
+		if name == "host" {
+			values = []string{requestHost(req)}
+		} else {
+			values = req.Header.Values(name)
+		}
+		trimmed := make([]string, len(values))
+		for i, v := range values {
+			trimmed[i] = strings.Join(strings.Fields(v), " ")
+		}
+		b.WriteString(name + ":" + strings.Join(trimmed, ",") + "\n")
+	}
+	return b.String()
+}
+
+// escapedPath 请求发送时使用的转义后的 path
+func escapedPath(u *url.URL) string {
+	path := u.EscapedPath()
+	if u.Opaque != "" {
+		path = u.Opaque
+	}
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+// canonicalPath SigV4 的规范 URI: 对解码后的 path 逐段 URI 编码, 除非保留字符与 '/' 外全部转义
+// 不使用 EscapedPath, Go 不转义 path 中的 "$&+,:;=@" 且会沿用调用方设置的 RawPath
+// double 为 true 时再编码一次(S3 以外的服务)
+func canonicalPath(u *url.URL, double bool) string {
+	path := u.Path
+	if u.Opaque != "" {
+		if p, err := url.PathUnescape(u.Opaque); err == nil {
+			path = p
+		}
+	}
+	if path == "" {
+		return "/"
+	}
+
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		segments[i] = uriEncode(s)
+		if double {
+			segments[i] = uriEncode(segments[i])
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// canonicalQuery 按 key、value 排序并转义的 query
+func canonicalQuery(u *url.URL) string {
+	query := u.Query()
+	pairs := make([][2]string, 0, len(query))
+	for k, values := range query {
+		for _, v := range values {
+			pairs = append(pairs, [2]string{uriEncode(k), uriEncode(v)})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i][0] != pairs[j][0] {
+			return pairs[i][0] < pairs[j][0]
+		}
+		return pairs[i][1] < pairs[j][1]
+	})
+
+	encoded := make([]string, len(pairs))
+	for i, p := range pairs {
+		encoded[i] = p[0] + "=" + p[1]
+	}
+	return strings.Join(encoded, "&")
+}
+
+// uriEncode 除 A-Z a-z 0-9 - _ . ~ 外的字符均转义为 %XX
+func uriEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
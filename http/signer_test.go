@@ -0,0 +1,134 @@
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSigV4Signer(t *testing.T) {
+	// AWS 文档中 IAM ListUsers 的示例请求
+	req, _ := http.NewRequest(http.MethodGet, "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+
+	signer := &SigV4Signer{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		Region:          "us-east-1",
+		Service:         "iam",
+	}
+	if err := signer.Sign(req, EmptyBodySHA256, time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, " +
+		"SignedHeaders=content-type;host;x-amz-date, " +
+		"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Fatalf("authorization\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestSigV4CanonicalPath(t *testing.T) {
+	tests := []struct {
+		rawURL string
+		single string // S3, 只编码一次
+		double string
+	}{
+		{"https://s3.amazonaws.com", "/", "/"},
+		{"https://s3.amazonaws.com/bucket/a=b", "/bucket/a%3Db", "/bucket/a%253Db"},
+		{"https://s3.amazonaws.com/bucket/x+y@z", "/bucket/x%2By%40z", "/bucket/x%252By%2540z"},
+		{"https://s3.amazonaws.com/bucket/$&,:;/~-_.", "/bucket/%24%26%2C%3A%3B/~-_.", "/bucket/%2524%2526%252C%253A%253B/~-_."},
+		{"https://s3.amazonaws.com/bucket/a%20b%2Fc", "/bucket/a%20b/c", "/bucket/a%2520b/c"},
+		{"https://s3.amazonaws.com/bucket/%E4%B8%AD", "/bucket/%E4%B8%AD", "/bucket/%25E4%25B8%25AD"},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.rawURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := canonicalPath(u, false); got != tt.single {
+			t.Fatalf("%s single: got %s, want %s", tt.rawURL, got, tt.single)
+		}
+		if got := canonicalPath(u, true); got != tt.double {
+			t.Fatalf("%s double: got %s, want %s", tt.rawURL, got, tt.double)
+		}
+	}
+
+	// 签名与调用方对 path 的转义方式无关
+	signer := &SigV4Signer{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret", Region: "us-east-1", Service: "s3", DisableURIPathEscaping: true}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	var signatures []string
+	for _, rawURL := range []string{"https://bucket.s3.amazonaws.com/x+y@z=1", "https://bucket.s3.amazonaws.com/x%2By%40z%3D1"} {
+		req, _ := http.NewRequest(http.MethodGet, rawURL, nil)
+		if err := signer.Sign(req, EmptyBodySHA256, now); err != nil {
+			t.Fatal(err)
+		}
+		signatures = append(signatures, req.Header.Get("Authorization"))
+	}
+	if signatures[0] != signatures[1] {
+		t.Fatalf("signature depends on path escaping:\n%s\n%s", signatures[0], signatures[1])
+	}
+}
+
+func TestClientHMACSigner(t *testing.T) {
+	signer := &HMACSigner{KeyID: "k1", Secret: []byte("secret"), SignedHeaders: []string{"Content-Type"}}
+
+	var attempts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		sum := sha256.Sum256(body)
+		bodyHash := hex.EncodeToString(sum[:])
+
+		// 服务端按同样的规则重新计算签名
+		signed := []string{"content-type", "host", "x-content-sha256", "x-timestamp"}
+		canonical := CanonicalRequest(r, signed, bodyHash, r.Header.Get("X-Timestamp"))
+		want := "HMAC-SHA256 Credential=k1, SignedHeaders=content-type;host;x-content-sha256;x-timestamp, Signature=" +
+			hex.EncodeToString(hmacSHA256([]byte("secret"), canonical))
+		if got := r.Header.Get("X-Signature"); got != want || r.Header.Get("X-Content-SHA256") != bodyHash {
+			t.Errorf("signature\n got %s\nwant %s", got, want)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", TextPlain)
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	policy := DefaultRetryPolicy()
+	policy.BaseDelay = time.Millisecond
+	policy.RetryNonIdempotent = true
+	c := NewClient(WithSigner(signer), WithRetry(policy))
+
+	req, err := c.NewRequest(http.MethodPost, WithURL(srv.URL+"/v1/orders?b=2&a=1&a=0"), WithBody(map[string]string{"id": "1"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var text string
+	resp, err := c.Do(req, WithResponseBodyData(&text), WithResponseStatusCheck(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || text != `{"id":"1"}` || atomic.LoadInt32(&attempts) != 2 {
+		t.Fatalf("status %d body %q attempts %d", resp.StatusCode, text, attempts)
+	}
+
+	// body 不可重放时读入内存后签名
+	raw, _ := http.NewRequest(http.MethodPut, srv.URL+"/v1/raw", ioutil.NopCloser(strings.NewReader("raw body")))
+	raw.Header.Set("Content-Type", TextPlain)
+	if _, err = c.Do(raw, WithResponseBodyData(&text), WithRequestRetry(RetryPolicy{})); err != nil || text != "raw body" {
+		t.Fatalf("raw body %q err %v", text, err)
+	}
+}
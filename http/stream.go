@@ -0,0 +1,199 @@
+package http
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// resumeSuffix 断点续传时记录资源校验值(ETag 或 Last-Modified)的文件后缀
+const resumeSuffix = ".resume"
+
+// WithResponseStream 以流的方式处理 2xx 响应 body, body 不会读入内存
+// fn 可提前关闭 body 以中止下载, 否则 fn 返回后 body 由 Do 关闭; 非 2xx 响应仍按普通方式读取 body
+func WithResponseStream(fn func(body io.ReadCloser) error) DoOptions {
+	return func(o *doOptions) {
+		o.stream = fn
+	}
+}
+
+// WithResponseWriter 将 2xx 响应 body 流式写入 w
+func WithResponseWriter(w io.Writer) DoOptions {
+	return WithResponseStream(func(body io.ReadCloser) error {
+		_, err := io.Copy(w, body)
+		return err
+	})
+}
+
+// WithDownloadProgress 配置下载进度回调, 仅在流式处理响应时生效
+// read 为已下载的字节数(断点续传时包含已有部分), total 为总字节数, 未知时为 -1
+func WithDownloadProgress(fn func(read int64, total int64)) DoOptions {
+	return func(o *doOptions) {
+		o.downloadProgress = fn
+	}
+}
+
+// WithResumeFile 将 2xx 响应 body 下载到 path, 支持断点续传
+// path 已存在时通过 Range/If-Range 请求剩余部分, 资源变化时服务端返回完整内容并重新写入
+// 下载过程中资源校验值保存在 path+".resume" 中, 下载完成后删除; 没有校验值时重新下载
+// 文件已完整时服务端返回 416, 此时 Do 返回 nil error; 416 响应的总长度与本地文件不一致时删除本地文件并返回错误, 再次请求时重新下载
+func WithResumeFile(path string) DoOptions {
+	return func(o *doOptions) {
+		o.resumeFile = path
+	}
+}
+
+// prepareResume 依据已下载的文件设置 Range 与 If-Range 请求头, 返回已下载的字节数
+func prepareResume(req *http.Request, path string) (*http.Request, int64) {
+	info, err := os.Stat(path)
+	if err != nil || info.Size() == 0 {
+		return req, 0
+	}
+	validator, err := ioutil.ReadFile(path + resumeSuffix)
+	if err != nil || len(validator) == 0 {
+		return req, 0
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-", info.Size()))
+	req.Header.Set("If-Range", string(validator))
+
+	return req, info.Size()
+}
+
+// streamResponse 流式处理 2xx 响应 body, 返回 false 表示响应需要按普通方式读取
+func streamResponse(resp *http.Response, opt *doOptions, offset int64) (bool, error) {
+	if opt.resumeFile != "" && resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		if _, total, ok := parseContentRange(resp.Header.Get("Content-Range")); ok && total == offset {
+			_ = os.Remove(opt.resumeFile + resumeSuffix)
+			return true, nil
+		}
+		if offset > 0 {
+			// 本地文件与服务端资源不一致, 保留会导致每次续传都返回 416
+			_ = os.Remove(opt.resumeFile)
+			_ = os.Remove(opt.resumeFile + resumeSuffix)
+			return true, fmt.Errorf("range not satisfiable: content range %q does not match local size %d, removed %s",
+				resp.Header.Get("Content-Range"), offset, opt.resumeFile)
+		}
+		return false, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return false, nil
+	}
+
+	if opt.resumeFile != "" {
+		return true, downloadFile(resp, opt, offset)
+	}
+
+	body := resp.Body
+	if opt.downloadProgress != nil {
+		body = struct {
+			io.Reader
+			io.Closer
+		}{progressReader(resp.Body, 0, resp.ContentLength, opt.downloadProgress), resp.Body}
+	}
+	return true, opt.stream(body)
+}
+
+// downloadFile 将响应 body 写入 opt.resumeFile, 206 响应追加写入, 其他响应覆盖写入
+func downloadFile(resp *http.Response, opt *doOptions, offset int64) error {
+	path := opt.resumeFile
+
+	flag := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	start, total := int64(0), resp.ContentLength
+	if resp.StatusCode == http.StatusPartialContent {
+		var ok bool
+		start, total, ok = parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok || start != offset {
+			return fmt.Errorf("unexpected content range %q, want start %d", resp.Header.Get("Content-Range"), offset)
+		}
+		flag = os.O_WRONLY | os.O_APPEND
+	}
+
+	validator := resp.Header.Get("ETag")
+	if validator == "" || strings.HasPrefix(validator, "W/") {
+		validator = resp.Header.Get("Last-Modified")
+	}
+	if validator != "" {
+		if err := ioutil.WriteFile(path+resumeSuffix, []byte(validator), 0644); err != nil {
+			return err
+		}
+	} else {
+		_ = os.Remove(path + resumeSuffix)
+	}
+
+	f, err := os.OpenFile(path, flag, 0644)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, progressReader(resp.Body, start, total, opt.downloadProgress))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	_ = os.Remove(path + resumeSuffix)
+	return nil
+}
+
+// parseContentRange 解析 "bytes start-end/total" 或 "bytes */total", total 未知时为 -1
+func parseContentRange(value string) (start int64, total int64, ok bool) {
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, "bytes ") {
+		return 0, 0, false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(value, "bytes "), "/", 2)
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+
+	total = -1
+	if parts[1] != "*" {
+		var err error
+		if total, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
+			return 0, 0, false
+		}
+	}
+
+	if parts[0] == "*" {
+		return 0, total, true
+	}
+	rng := strings.SplitN(parts[0], "-", 2)
+	start, err := strconv.ParseInt(rng[0], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return start, total, true
+}
+
+// progressReader 包装 r, 每次读取后回调已读取的字节数
+func progressReader(r io.Reader, read int64, total int64, fn func(read int64, total int64)) io.Reader {
+	if fn == nil {
+		return r
+	}
+	return &countingReader{r: r, read: read, total: total, fn: fn}
+}
+
+type countingReader struct {
+	r     io.Reader
+	read  int64
+	total int64
+	fn    func(read int64, total int64)
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if n > 0 {
+		c.read += int64(n)
+		c.fn(c.read, c.total)
+	}
+	return n, err
+}
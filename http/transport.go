@@ -0,0 +1,188 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"time"
+)
+
+// transportOptions http.Transport 相关配置, 未配置的字段沿用 http.DefaultTransport 的设置
+type transportOptions struct {
+	rootCAs      *x509.CertPool
+	certificates []tls.Certificate
+	minVersion   uint16
+	serverName   string
+
+	maxIdleConns          int
+	maxIdleConnsPerHost   int
+	maxConnsPerHost       int
+	idleConnTimeout       time.Duration
+	responseHeaderTimeout time.Duration
+	tlsHandshakeTimeout   time.Duration
+
+	dialTimeout time.Duration
+	keepAlive   time.Duration
+}
+
+// WithRootCAs 配置校验服务端证书的 CA 证书池, 默认使用系统 CA
+func WithRootCAs(pool *x509.CertPool) Options {
+	return func(o *options) {
+		o.transport.rootCAs = pool
+	}
+}
+
+// WithClientCertificate 配置客户端证书, 用于 mTLS
+// 证书可通过 tls.LoadX509KeyPair 或 tls.X509KeyPair 加载
+func WithClientCertificate(certs ...tls.Certificate) Options {
+	return func(o *options) {
+		o.transport.certificates = append(o.transport.certificates[:len(o.transport.certificates):len(o.transport.certificates)], certs...)
+	}
+}
+
+// WithMinTLSVersion 配置最低 TLS 版本, 如 tls.VersionTLS12
+func WithMinTLSVersion(version uint16) Options {
+	return func(o *options) {
+		o.transport.minVersion = version
+	}
+}
+
+// WithServerName 配置 TLS 握手的 SNI 以及证书校验使用的服务端名称
+func WithServerName(serverName string) Options {
+	return func(o *options) {
+		o.transport.serverName = serverName
+	}
+}
+
+// WithMaxIdleConns 配置所有 host 的最大空闲连接数
+func WithMaxIdleConns(n int) Options {
+	return func(o *options) {
+		o.transport.maxIdleConns = n
+	}
+}
+
+// WithMaxIdleConnsPerHost 配置每个 host 的最大空闲连接数, 默认为 2
+func WithMaxIdleConnsPerHost(n int) Options {
+	return func(o *options) {
+		o.transport.maxIdleConnsPerHost = n
+	}
+}
+
+// WithMaxConnsPerHost 配置每个 host 的最大连接数(包含使用中的连接), 默认不限制
+func WithMaxConnsPerHost(n int) Options {
+	return func(o *options) {
+		o.transport.maxConnsPerHost = n
+	}
+}
+
+// WithIdleConnTimeout 配置空闲连接的关闭时间
+func WithIdleConnTimeout(timeout time.Duration) Options {
+	return func(o *options) {
+		o.transport.idleConnTimeout = timeout
+	}
+}
+
+// WithResponseHeaderTimeout 配置请求发送完成后等待响应头的超时时间
+func WithResponseHeaderTimeout(timeout time.Duration) Options {
+	return func(o *options) {
+		o.transport.responseHeaderTimeout = timeout
+	}
+}
+
+// WithTLSHandshakeTimeout 配置 TLS 握手超时时间
+func WithTLSHandshakeTimeout(timeout time.Duration) Options {
+	return func(o *options) {
+		o.transport.tlsHandshakeTimeout = timeout
+	}
+}
+
+// WithDialTimeout 配置建立连接的超时时间
+func WithDialTimeout(timeout time.Duration) Options {
+	return func(o *options) {
+		o.transport.dialTimeout = timeout
+	}
+}
+
+// WithKeepAlive 配置 TCP keep-alive 探测间隔, 负数表示关闭 TCP keep-alive
+func WithKeepAlive(keepAlive time.Duration) Options {
+	return func(o *options) {
+		o.transport.keepAlive = keepAlive
+	}
+}
+
+// dialer 依据配置创建 net.Dialer, 默认值与 http.DefaultTransport 一致
+func (o *transportOptions) dialer() *net.Dialer {
+	d := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	if o.dialTimeout > 0 {
+		d.Timeout = o.dialTimeout
+	}
+	if o.keepAlive != 0 {
+		d.KeepAlive = o.keepAlive
+	}
+	return d
+}
+
+// newTransport 在 http.DefaultTransport 的副本上应用配置, 保留连接池、TLS 握手超时以及 HTTP/2 等默认设置
+func (o *transportOptions) newTransport() *http.Transport {
+	var ts *http.Transport
+	if dt, ok := http.DefaultTransport.(*http.Transport); ok {
+		ts = dt.Clone()
+	} else {
+		ts = &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: time.Second,
+		}
+	}
+	ts.DialContext = o.dialer().DialContext
+
+	if o.rootCAs != nil || len(o.certificates) > 0 || o.minVersion != 0 || o.serverName != "" {
+		cfg := ts.TLSClientConfig
+		if cfg == nil {
+			cfg = &tls.Config{}
+		} else {
+			cfg = cfg.Clone()
+		}
+		if o.rootCAs != nil {
+			cfg.RootCAs = o.rootCAs
+		}
+		if len(o.certificates) > 0 {
+			cfg.Certificates = o.certificates
+		}
+		if o.minVersion != 0 {
+			cfg.MinVersion = o.minVersion
+		}
+		if o.serverName != "" {
+			cfg.ServerName = o.serverName
+		}
+		ts.TLSClientConfig = cfg
+	}
+
+	if o.maxIdleConns > 0 {
+		ts.MaxIdleConns = o.maxIdleConns
+	}
+	if o.maxIdleConnsPerHost > 0 {
+		ts.MaxIdleConnsPerHost = o.maxIdleConnsPerHost
+	}
+	if o.maxConnsPerHost > 0 {
+		ts.MaxConnsPerHost = o.maxConnsPerHost
+	}
+	if o.idleConnTimeout > 0 {
+		ts.IdleConnTimeout = o.idleConnTimeout
+	}
+	if o.responseHeaderTimeout > 0 {
+		ts.ResponseHeaderTimeout = o.responseHeaderTimeout
+	}
+	if o.tlsHandshakeTimeout > 0 {
+		ts.TLSHandshakeTimeout = o.tlsHandshakeTimeout
+	}
+
+	return ts
+}
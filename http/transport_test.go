@@ -0,0 +1,65 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientTransportOptions(t *testing.T) {
+	pool := x509.NewCertPool()
+	c := NewClient(
+		WithRootCAs(pool),
+		WithMinTLSVersion(tls.VersionTLS12),
+		WithServerName("example.com"),
+		WithMaxIdleConnsPerHost(20),
+		WithIdleConnTimeout(time.Minute),
+		WithResponseHeaderTimeout(5*time.Second),
+		WithTLSHandshakeTimeout(3*time.Second),
+	)
+
+	ts, ok := c.HTTPClient.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("transport type %T", c.HTTPClient.Transport)
+	}
+	if ts.TLSClientConfig.RootCAs != pool || ts.TLSClientConfig.MinVersion != tls.VersionTLS12 || ts.TLSClientConfig.ServerName != "example.com" {
+		t.Fatalf("tls config not applied: %+v", ts.TLSClientConfig)
+	}
+	if ts.MaxIdleConnsPerHost != 20 || ts.IdleConnTimeout != time.Minute || ts.ResponseHeaderTimeout != 5*time.Second || ts.TLSHandshakeTimeout != 3*time.Second {
+		t.Fatalf("pool config not applied: %+v", ts)
+	}
+	if !ts.ForceAttemptHTTP2 || ts.MaxIdleConns != http.DefaultTransport.(*http.Transport).MaxIdleConns || ts.Proxy == nil {
+		t.Fatalf("default transport settings should be kept")
+	}
+}
+
+func TestClientMutualTLS(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	srv.StartTLS()
+	defer srv.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+
+	// 复用服务端证书作为客户端证书
+	c := NewClient(WithRootCAs(pool), WithClientCertificate(srv.TLS.Certificates...))
+	req, _ := c.NewRequest(http.MethodGet, WithURL(srv.URL))
+	resp, err := c.Do(req)
+	if err != nil || !resp.IsOK() {
+		t.Fatalf("mtls request err %v resp %v", err, resp)
+	}
+
+	c = NewClient()
+	req, _ = c.NewRequest(http.MethodGet, WithURL(srv.URL))
+	if _, err = c.Do(req); err == nil {
+		t.Fatalf("expect certificate verify error")
+	}
+}
@@ -0,0 +1,183 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+)
+
+// WithBaseURL 配置 client 的基础 url, WithURL 传入不带 scheme 与 host 的路径时拼接在基础 url 的路径之后
+// 如基础 url 为 "https://api.example.com/v1", WithURL("/users") 请求 "https://api.example.com/v1/users"
+// 基础 url 中的 query 作为默认 query; url 无效时通过 Err 返回, 满足 errors.Is(err, ErrInvalidConfig)
+func WithBaseURL(rawURL string) Options {
+	return func(o *options) {
+		o.baseURL = rawURL
+	}
+}
+
+// WithDefaultHeader 追加 client 的默认请求头, 同名请求头以后配置的为准
+// 请求通过 WithHeader 配置的同名请求头覆盖默认值, 值为空时不发送该请求头; header 会被复制, 调用方可继续修改
+func WithDefaultHeader(header http.Header) Options {
+	return func(o *options) {
+		o.header = mergeHeader(o.header, header)
+	}
+}
+
+// WithUserAgent 设置默认 User-Agent, 可被请求的 WithHeader 覆盖
+func WithUserAgent(userAgent string) Options {
+	return WithDefaultHeader(http.Header{"User-Agent": {userAgent}})
+}
+
+// WithDefaultQuery 追加 client 的默认 query, 同名 key 以后配置的为准
+// url 或 WithQuery 中已有的 key 不使用默认值
+func WithDefaultQuery(values url.Values) Options {
+	return func(o *options) {
+		q := make(url.Values, len(o.query)+len(values))
+		for k, vs := range o.query {
+			q[k] = vs
+		}
+		for k, vs := range values {
+			q[k] = append([]string(nil), vs...)
+		}
+		o.query = q
+	}
+}
+
+// WithPathParam 设置路径模板参数, url 路径中的 {name} 替换为转义后的 value, 如 "/users/{id}/orders/{orderID}"
+// 配置了路径参数时模板中的参数必须全部设置, 否则 NewRequest 返回错误
+func WithPathParam(name string, value string) RequestOptions {
+	return WithPathParams(map[string]string{name: value})
+}
+
+// WithPathParams 批量设置路径模板参数, 详见 WithPathParam
+func WithPathParams(params map[string]string) RequestOptions {
+	return func(o *requestOptions) {
+		m := make(map[string]string, len(o.pathParams)+len(params))
+		for k, v := range o.pathParams {
+			m[k] = v
+		}
+		for k, v := range params {
+			m[k] = v
+		}
+		o.pathParams = m
+	}
+}
+
+// mergeHeader 返回 dst 与 src 合并后的新 header, src 中的 key 覆盖 dst, 不修改 dst 与 src
+func mergeHeader(dst http.Header, src http.Header) http.Header {
+	h := make(http.Header, len(dst)+len(src))
+	for k, vs := range dst {
+		h[k] = append([]string(nil), vs...)
+	}
+	for k, vs := range src {
+		h[textproto.CanonicalMIMEHeaderKey(k)] = append([]string(nil), vs...)
+	}
+	return h
+}
+
+// parseBaseURL 解析基础 url, 将其 query 合并到默认 query 中
+func parseBaseURL(rawURL string, query url.Values) (*url.URL, url.Values, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, nil, fmt.Errorf("%w: invalid base url %q", ErrInvalidConfig, rawURL)
+	}
+	if u.RawQuery == "" {
+		return u, query, nil
+	}
+
+	q := u.Query()
+	for k, vs := range query {
+		q[k] = vs
+	}
+	u.RawQuery = ""
+	return u, q, nil
+}
+
+// requestURL 展开路径参数, 拼接基础 url 并合并 query
+func (c *Client) requestURL(opt *requestOptions) (string, error) {
+	rawURL := opt.url
+	if opt.pathParams != nil {
+		var err error
+		if rawURL, err = expandPath(rawURL, opt.pathParams); err != nil {
+			return "", err
+		}
+	}
+	if c.baseURL != nil {
+		var err error
+		if rawURL, err = joinURL(c.baseURL, rawURL); err != nil {
+			return "", err
+		}
+	}
+
+	rawURL, err := mergeQuery(rawURL, c.opt.query, opt.query)
+	if err != nil {
+		return "", fmt.Errorf("build query err %w", err)
+	}
+	return rawURL, nil
+}
+
+// expandPath 将 url 路径中的 {name} 替换为 url.PathEscape 转义后的参数
+// "." 与 ".." 同样转义, 避免参数改变请求的路径层级
+func expandPath(rawURL string, params map[string]string) (string, error) {
+	end := strings.IndexAny(rawURL, "?#")
+	if end < 0 {
+		end = len(rawURL)
+	}
+	path := rawURL[:end]
+
+	var b strings.Builder
+	for {
+		i := strings.IndexByte(path, '{')
+		if i < 0 {
+			b.WriteString(path)
+			break
+		}
+		j := strings.IndexByte(path[i:], '}')
+		if j < 0 {
+			return "", fmt.Errorf("unclosed path param in %q", rawURL)
+		}
+		name := path[i+1 : i+j]
+		value, ok := params[name]
+		if !ok {
+			return "", fmt.Errorf("missing path param %q in %q", name, rawURL)
+		}
+
+		b.WriteString(path[:i])
+		switch value {
+		case ".":
+			b.WriteString("%2E")
+		case "..":
+			b.WriteString("%2E%2E")
+		default:
+			b.WriteString(url.PathEscape(value))
+		}
+		path = path[i+j+1:]
+	}
+
+	return b.String() + rawURL[end:], nil
+}
+
+// joinURL 将不带 scheme 与 host 的 ref 拼接到 base 的路径之后, 带 host 的 ref 按 RFC 3986 解析
+func joinURL(base *url.URL, ref string) (string, error) {
+	r, err := url.Parse(ref)
+	if err != nil {
+		return "", fmt.Errorf("parse url err %w", err)
+	}
+	if r.Scheme != "" || r.Host != "" {
+		return base.ResolveReference(r).String(), nil
+	}
+
+	u := *base
+	u.RawQuery = r.RawQuery
+	u.Fragment = r.Fragment
+	if p := r.EscapedPath(); p != "" {
+		escaped := strings.TrimSuffix(base.EscapedPath(), "/") + "/" + strings.TrimPrefix(p, "/")
+		if u.Path, err = url.PathUnescape(escaped); err != nil {
+			return "", fmt.Errorf("parse url err %w", err)
+		}
+		u.RawPath = escaped
+	}
+	return u.String(), nil
+}
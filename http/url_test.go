@@ -0,0 +1,96 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestClientBaseURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", TextPlain)
+		w.Write([]byte(r.URL.EscapedPath() + "?" + r.URL.RawQuery))
+	}))
+	defer srv.Close()
+
+	c := NewClient(WithBaseURL(srv.URL+"/v1/?key=k"), WithDefaultQuery(url.Values{"lang": {"en"}}))
+	tests := []struct {
+		url    string
+		params map[string]string
+		query  url.Values
+		want   string
+	}{
+		{url: "", want: "/v1/?key=k&lang=en"},
+		{url: "/users", want: "/v1/users?key=k&lang=en"},
+		{url: "users/?page=2", want: "/v1/users/?page=2&key=k&lang=en"},
+		{url: "/users/{id}/orders/{orderID}", params: map[string]string{"id": "a b/c", "orderID": ".."},
+			want: "/v1/users/a%20b%2Fc/orders/%2E%2E?key=k&lang=en"},
+		{url: "/signed?X-Signature=a%2Bb&z=1&a=2", want: "/v1/signed?X-Signature=a%2Bb&z=1&a=2&key=k&lang=en"},
+		{url: "/search", query: url.Values{"lang": {"zh"}}, want: "/v1/search?key=k&lang=zh"},
+		{url: srv.URL + "/other?lang=zh", want: "/other?lang=zh&key=k"},
+	}
+	for _, tt := range tests {
+		opts := []RequestOptions{WithURL(tt.url)}
+		if tt.params != nil {
+			opts = append(opts, WithPathParams(tt.params))
+		}
+		if tt.query != nil {
+			opts = append(opts, WithQuery(tt.query))
+		}
+		req, err := c.NewRequest(http.MethodGet, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var text string
+		if _, err = c.Do(req, WithResponseBodyData(&text)); err != nil {
+			t.Fatal(err)
+		}
+		if text != tt.want {
+			t.Fatalf("%q: got %q, want %q", tt.url, text, tt.want)
+		}
+	}
+
+	if _, err := c.NewRequest(http.MethodGet, WithURL("/users/{id}"), WithPathParam("name", "x")); err == nil {
+		t.Fatal("missing path param should return error")
+	}
+	if err := NewClient(WithBaseURL("/relative")).Err(); !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("expect ErrInvalidConfig, got %v", err)
+	}
+}
+
+func TestClientDefaultHeader(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", TextPlain)
+		w.Write([]byte(r.UserAgent() + "|" + r.Header.Get("X-Token") + "|" + r.Header.Get("X-Trace")))
+	}))
+	defer srv.Close()
+
+	defaults := http.Header{"X-Token": {"default"}}
+	c := NewClient(WithDefaultHeader(defaults), WithUserAgent("utils/1.0"))
+	defaults.Set("X-Token", "changed")
+
+	if got := doText(t, c, srv.URL); got != "utils/1.0|default|" {
+		t.Fatalf("default header %q", got)
+	}
+
+	shared := http.Header{"x-trace": {"t1"}}
+	for i := 0; i < 2; i++ {
+		req, err := c.NewRequest(http.MethodPost, WithURL(srv.URL), WithHeader(shared),
+			WithHeader(http.Header{"X-Token": {"request"}, "User-Agent": nil}), WithBody(map[string]int{"i": i}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var text string
+		if _, err = c.Do(req, WithResponseBodyData(&text)); err != nil {
+			t.Fatal(err)
+		}
+		if text != "|request|t1" {
+			t.Fatalf("request header %q", text)
+		}
+	}
+	if len(shared) != 1 || shared.Get("Content-Type") != "" {
+		t.Fatalf("caller header should not be mutated: %v", shared)
+	}
+}